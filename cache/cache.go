@@ -0,0 +1,264 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cache implements a typed concurrent in-memory cache with
+// expiration, eviction of the least recently used entries and
+// deduplicated loading of missing entries.
+package cache
+
+import (
+	"sync"
+	"time"
+
+	xsync "golang.design/x/go2generics/sync"
+	"golang.design/x/go2generics/sync/atomic"
+)
+
+// Cache is a concurrent-safe cache from keys of type K to values of
+// type V. A Cache must be created with New.
+//
+// Reads do not take a lock. Instead of moving an entry to the front of
+// the recency list, a read marks it as used, and an entry that is marked
+// when it reaches the back of the list is given a second chance. The
+// eviction order is therefore close to, but not exactly, least recently
+// used.
+type Cache[K comparable, V any] struct {
+	// the counters come first to be 64-bit aligned on 32-bit platforms.
+	hits, misses, evictions, expirations atomic.Int[uint64]
+
+	opts options
+	now  func() time.Time
+
+	// m serves reads without locking. It is modified only with mu held
+	// so that it agrees with lru.
+	m xsync.ComparableMap[K, *entry[K, V]]
+	// mu protects lru and purge
+	mu  sync.Mutex
+	lru list[*entry[K, V]]
+	// purge is the time in Unix nanoseconds after which Set removes the
+	// expired entries, zero if no entry expires.
+	purge int64
+
+	loads xsync.Group[K, V]
+}
+
+// entry is an immutable cached value. Setting a key replaces its entry.
+type entry[K comparable, V any] struct {
+	key   K
+	value V
+	// expires is the expiration time in Unix nanoseconds, zero if the
+	// entry does not expire.
+	expires int64
+	// used is set by reads and cleared when the entry is given a second
+	// chance at eviction.
+	used atomic.Bool
+	// elem is the element of the entry in lru, guarded by mu.
+	elem *element[*entry[K, V]]
+}
+
+type options struct {
+	ttl        time.Duration
+	maxEntries int
+}
+
+// Option configures a Cache.
+type Option func(o *options)
+
+// WithTTL sets the time to live of entries added by Set. By default
+// entries do not expire.
+func WithTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.ttl = d
+	}
+}
+
+// WithMaxEntries limits the number of entries, beyond which the least
+// recently used entries are evicted. By default the number is unlimited.
+func WithMaxEntries(n int) Option {
+	return func(o *options) {
+		o.maxEntries = n
+	}
+}
+
+// Stats are the statistics of a Cache.
+type Stats struct {
+	// Hits is the number of reads that found a live entry.
+	Hits uint64
+	// Misses is the number of reads that found no live entry.
+	Misses uint64
+	// Evictions is the number of entries removed to respect the
+	// maximum number of entries.
+	Evictions uint64
+	// Expirations is the number of expired entries removed.
+	Expirations uint64
+}
+
+// New returns an empty cache.
+func New[K comparable, V any](opts ...Option) *Cache[K, V] {
+	c := &Cache[K, V]{now: time.Now}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+	return c
+}
+
+// Get returns the value cached for key, if there is one that has not
+// expired.
+func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
+	e, ok := c.load(key)
+	if !ok {
+		c.misses.Add(1)
+		return value, false
+	}
+	c.hits.Add(1)
+	if !e.used.Load() {
+		e.used.Store(true)
+	}
+	return e.value, true
+}
+
+// load returns the live entry of key, removing it if it has expired.
+func (c *Cache[K, V]) load(key K) (*entry[K, V], bool) {
+	e, ok := c.m.Load(key)
+	if !ok {
+		return nil, false
+	}
+	if e.expires != 0 && c.now().UnixNano() >= e.expires {
+		c.mu.Lock()
+		if c.m.CompareAndDelete(key, e) {
+			c.lru.remove(e.elem)
+			c.expirations.Add(1)
+		}
+		c.mu.Unlock()
+		return nil, false
+	}
+	return e, true
+}
+
+// Set caches value for key with the time to live of the cache.
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.opts.ttl)
+}
+
+// SetWithTTL caches value for key for the duration ttl. The entry does
+// not expire if ttl is zero or negative.
+//
+// SetWithTTL also removes the expired entries that are not read again,
+// at most once per time to live of the entries, so that a cache whose
+// entries expire does not grow without bound.
+func (c *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	now := c.now()
+	e := &entry[K, V]{key: key, value: value}
+	if ttl > 0 {
+		e.expires = now.Add(ttl).UnixNano()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.purge != 0 && now.UnixNano() >= c.purge {
+		c.purgeLocked(now.UnixNano())
+	}
+	if c.purge == 0 {
+		c.purge = e.expires
+	}
+	if old, loaded := c.m.Swap(key, e); loaded {
+		c.lru.remove(old.elem)
+	}
+	e.elem = c.lru.pushFront(e)
+	c.evictLocked()
+}
+
+// evictLocked removes entries from the back of lru until the cache
+// respects its maximum number of entries.
+func (c *Cache[K, V]) evictLocked() {
+	if c.opts.maxEntries <= 0 {
+		return
+	}
+	now := c.now().UnixNano()
+	for c.lru.len > c.opts.maxEntries {
+		elem := c.lru.back()
+		e := elem.value
+		expired := e.expires != 0 && now >= e.expires
+		if !expired && e.used.Load() {
+			e.used.Store(false)
+			c.lru.moveToFront(elem)
+			continue
+		}
+		c.m.Delete(e.key)
+		c.lru.remove(elem)
+		if expired {
+			c.expirations.Add(1)
+		} else {
+			c.evictions.Add(1)
+		}
+	}
+}
+
+// Purge removes the expired entries.
+func (c *Cache[K, V]) Purge() {
+	c.mu.Lock()
+	c.purgeLocked(c.now().UnixNano())
+	c.mu.Unlock()
+}
+
+// purgeLocked removes the entries that are expired at now.
+func (c *Cache[K, V]) purgeLocked(now int64) {
+	for elem := c.lru.back(); elem != nil; {
+		prev := c.lru.prev(elem)
+		if e := elem.value; e.expires != 0 && now >= e.expires {
+			c.m.Delete(e.key)
+			c.lru.remove(elem)
+			c.expirations.Add(1)
+		}
+		elem = prev
+	}
+	c.purge = 0
+}
+
+// GetOrLoad returns the value cached for key, or calls load to compute
+// and cache it if there is none. Concurrent misses of the same key share
+// a single call of load. Errors of load are returned and not cached.
+func (c *Cache[K, V]) GetOrLoad(key K, load func() (V, error)) (V, error) {
+	if v, ok := c.Get(key); ok {
+		return v, nil
+	}
+	v, err, _ := c.loads.Do(key, func() (V, error) {
+		// The key may have been loaded by a call that completed after
+		// the miss above.
+		if e, ok := c.load(key); ok {
+			return e.value, nil
+		}
+		v, err := load()
+		if err == nil {
+			c.Set(key, v)
+		}
+		return v, err
+	})
+	return v, err
+}
+
+// Delete removes the entry of key.
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	if e, ok := c.m.LoadAndDelete(key); ok {
+		c.lru.remove(e.elem)
+	}
+	c.mu.Unlock()
+}
+
+// Len returns the number of entries in the cache, including expired
+// entries that have not been removed yet, see SetWithTTL and Purge.
+func (c *Cache[K, V]) Len() int {
+	return c.m.Len()
+}
+
+// Stats returns the statistics of the cache.
+func (c *Cache[K, V]) Stats() Stats {
+	return Stats{
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Evictions:   c.evictions.Load(),
+		Expirations: c.expirations.Load(),
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeNow is a manually advanced clock for the now function of a cache.
+type fakeNow struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (f *fakeNow) now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.t
+}
+
+func (f *fakeNow) advance(d time.Duration) {
+	f.mu.Lock()
+	f.t = f.t.Add(d)
+	f.mu.Unlock()
+}
+
+func TestCache(t *testing.T) {
+	c := New[string, int]()
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get on an empty cache succeeded")
+	}
+	c.Set("a", 1)
+	c.Set("a", 2)
+	if v, ok := c.Get("a"); v != 2 || !ok {
+		t.Fatalf("Get = %v, %v, want 2, true", v, ok)
+	}
+	if c.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", c.Len())
+	}
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok || c.Len() != 0 {
+		t.Fatal("Get succeeded after Delete")
+	}
+	if got, want := c.Stats(), (Stats{Hits: 1, Misses: 2}); got != want {
+		t.Fatalf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	clock := &fakeNow{t: time.Unix(0, 0)}
+	c := New[string, int](WithTTL(time.Minute))
+	c.now = clock.now
+
+	c.Set("a", 1)
+	c.SetWithTTL("b", 2, time.Hour)
+	c.SetWithTTL("c", 3, 0)
+	clock.advance(time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("entry did not expire after the TTL of the cache")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Fatal("entry with its own TTL expired early")
+	}
+	clock.advance(time.Hour)
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("entry did not expire after its own TTL")
+	}
+	if _, ok := c.Get("c"); !ok {
+		t.Fatal("entry without TTL expired")
+	}
+	if c.Len() != 1 || c.Stats().Expirations != 2 {
+		t.Fatalf("Len() = %d, Stats() = %+v, want 1 entry and 2 expirations", c.Len(), c.Stats())
+	}
+}
+
+func TestCachePurge(t *testing.T) {
+	clock := &fakeNow{t: time.Unix(0, 0)}
+	c := New[int, int](WithTTL(time.Minute))
+	c.now = clock.now
+
+	// entries that are never read again are removed by later sets, at
+	// most once per TTL.
+	for i := 0; i < 1000; i++ {
+		c.Set(i, i)
+		clock.advance(time.Second)
+		if n := c.Len(); n > 2*60 {
+			t.Fatalf("Len() = %d, expired entries are not removed by Set", n)
+		}
+	}
+
+	c.SetWithTTL(-1, -1, 0)
+	clock.advance(time.Minute)
+	c.Purge()
+	if _, ok := c.m.Load(-1); !ok || c.Len() != 1 {
+		t.Fatalf("Len() = %d, want only the entry without TTL", c.Len())
+	}
+	if got := c.Stats().Expirations; got != 1000 {
+		t.Fatalf("Stats().Expirations = %d, want 1000", got)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	c := New[string, int](WithMaxEntries(2))
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Set("c", 3)
+	if _, ok := c.m.Load("b"); ok {
+		t.Fatal("least recently used entry was not evicted")
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, ok := c.m.Load(k); !ok {
+			t.Fatalf("entry %s was evicted", k)
+		}
+	}
+
+	// The second chance of a is used up once it reaches the back again.
+	c.Set("d", 4)
+	c.Set("e", 5)
+	if _, ok := c.m.Load("a"); ok {
+		t.Fatal("entry given a second chance was not evicted when unused")
+	}
+	if c.Len() != 2 || c.Stats().Evictions != 3 {
+		t.Fatalf("Len() = %d, Stats() = %+v, want 2 entries and 3 evictions", c.Len(), c.Stats())
+	}
+}
+
+func TestCacheEvictExpiredFirst(t *testing.T) {
+	clock := &fakeNow{t: time.Unix(0, 0)}
+	c := New[string, int](WithMaxEntries(2))
+	c.now = clock.now
+
+	c.SetWithTTL("a", 1, time.Second)
+	c.Get("a")
+	c.Set("b", 2)
+	clock.advance(time.Second)
+	c.Set("c", 3)
+	if _, ok := c.m.Load("a"); ok {
+		t.Fatal("expired entry survived eviction because it was used")
+	}
+	if s := c.Stats(); s.Expirations != 1 || s.Evictions != 0 {
+		t.Fatalf("Stats() = %+v, want 1 expiration and no eviction", s)
+	}
+}
+
+func TestCacheGetOrLoad(t *testing.T) {
+	c := New[int, string]()
+
+	var calls int32
+	release := make(chan struct{})
+	load := func() (string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "v", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.GetOrLoad(1, load)
+			if v != "v" || err != nil {
+				t.Errorf("GetOrLoad = %v, %v, want v, <nil>", v, err)
+			}
+		}()
+	}
+	for atomic.LoadInt32(&calls) == 0 {
+		runtime.Gosched()
+	}
+	// Let the other callers join the load in flight, a late one finds
+	// the loaded entry instead.
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("load called %d times, want 1", n)
+	}
+
+	want := errors.New("failed")
+	if _, err := c.GetOrLoad(2, func() (string, error) { return "", want }); err != want {
+		t.Fatalf("GetOrLoad error = %v, want %v", err, want)
+	}
+	if _, ok := c.Get(2); ok {
+		t.Fatal("failed load was cached")
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	const keys = 64
+
+	c := New[int, string](WithMaxEntries(keys/2), WithTTL(time.Millisecond))
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1<<10; i++ {
+				k := (i * (g + 1)) % keys
+				switch i % 4 {
+				case 0:
+					c.Set(k, fmt.Sprint(k))
+				case 1:
+					c.Delete(k)
+				default:
+					if v, ok := c.Get(k); ok && v != fmt.Sprint(k) {
+						t.Errorf("Get(%d) = %q", k, v)
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if c.Len() > keys/2 || c.Len() != c.lru.len {
+		t.Fatalf("Len() = %d, recency list holds %d, want at most %d", c.Len(), c.lru.len, keys/2)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+// list is a doubly linked list, which orders the entries of a cache by
+// recency. Its zero value is an empty list.
+type list[T any] struct {
+	// root is a sentinel element, root.next is the front of the list
+	// and root.prev is the back.
+	root element[T]
+	len  int
+}
+
+// element is an element of a list.
+type element[T any] struct {
+	next, prev *element[T]
+	value      T
+}
+
+func (l *list[T]) lazyInit() {
+	if l.root.next == nil {
+		l.root.next = &l.root
+		l.root.prev = &l.root
+	}
+}
+
+// pushFront inserts v at the front of the list and returns its element.
+func (l *list[T]) pushFront(v T) *element[T] {
+	l.lazyInit()
+	e := &element[T]{value: v}
+	l.insertAfter(e, &l.root)
+	l.len++
+	return e
+}
+
+// back returns the last element of the list or nil if the list is empty.
+func (l *list[T]) back() *element[T] {
+	if l.len == 0 {
+		return nil
+	}
+	return l.root.prev
+}
+
+// prev returns the element before e, which must be an element of l, or
+// nil if e is the front.
+func (l *list[T]) prev(e *element[T]) *element[T] {
+	if e.prev == &l.root {
+		return nil
+	}
+	return e.prev
+}
+
+// remove removes e, which must be an element of l.
+func (l *list[T]) remove(e *element[T]) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.next, e.prev = nil, nil
+	l.len--
+}
+
+// moveToFront moves e, which must be an element of l, to the front.
+func (l *list[T]) moveToFront(e *element[T]) {
+	if l.root.next == e {
+		return
+	}
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	l.insertAfter(e, &l.root)
+}
+
+func (l *list[T]) insertAfter(e, at *element[T]) {
+	e.prev = at
+	e.next = at.next
+	at.next.prev = e
+	at.next = e
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func values[T any](l *list[T]) (vs []T) {
+	if l.len == 0 {
+		return nil
+	}
+	for e := l.root.next; e != &l.root; e = e.next {
+		vs = append(vs, e.value)
+	}
+	return vs
+}
+
+func TestList(t *testing.T) {
+	var l list[int]
+	if l.back() != nil {
+		t.Fatal("back of an empty list is not nil")
+	}
+	e1 := l.pushFront(1)
+	e2 := l.pushFront(2)
+	e3 := l.pushFront(3)
+	if got := values(&l); !reflect.DeepEqual(got, []int{3, 2, 1}) {
+		t.Fatalf("got %v, want [3 2 1]", got)
+	}
+	if l.back() != e1 {
+		t.Fatalf("back is %v, want 1", l.back().value)
+	}
+	if l.prev(e1) != e2 || l.prev(e2) != e3 || l.prev(e3) != nil {
+		t.Fatal("prev does not walk from the back to the front")
+	}
+
+	l.moveToFront(e1)
+	l.moveToFront(e1)
+	if got := values(&l); !reflect.DeepEqual(got, []int{1, 3, 2}) {
+		t.Fatalf("got %v, want [1 3 2]", got)
+	}
+
+	l.remove(e3)
+	l.remove(e2)
+	if got := values(&l); !reflect.DeepEqual(got, []int{1}) || l.len != 1 {
+		t.Fatalf("got %v of length %d, want [1]", got, l.len)
+	}
+	l.remove(e1)
+	if l.back() != nil || l.len != 0 {
+		t.Fatal("list is not empty after removing every element")
+	}
+}
@@ -4,38 +4,77 @@
 
 package chans
 
-import "runtime"
+import (
+	"context"
+	"runtime"
+	"sync"
+)
 
 // Ranger returns a Sender and a Receiver. The Receiver provides a
 // Next method to retrieve values. The Sender provides a Send method
 // to send values and a Close method to stop sending values. The Next
 // method indicates when the Sender has been closed, and the Send
-// method indicates when the Receiver has been freed.
+// method indicates when the Receiver has been closed or freed.
 //
 // This is a convenient way to exit a goroutine sending values when
-// the receiver stops reading them.
+// the receiver stops reading them. The receiver should call Close
+// when it stops early, freeing the Receiver is only noticed after a
+// garbage collection.
 func Ranger[T any]() (*Sender[T], *Receiver[T]) {
 	c := make(chan T)
-	d := make(chan bool)
+	d := &done{c: make(chan struct{})}
 	s := &Sender[T]{values: c, done: d}
 	r := &Receiver[T]{values: c, done: d}
-	runtime.SetFinalizer(r, r.finalize)
+	runtime.SetFinalizer(r, (*Receiver[T]).finalize)
 	return s, r
 }
 
+// RangerContext is like Ranger, but the Receiver is also closed when
+// ctx is done.
+func RangerContext[T any](ctx context.Context) (*Sender[T], *Receiver[T]) {
+	s, r := Ranger[T]()
+	// Refer to the done signal rather than the Receiver, so that the
+	// Receiver can still be freed while ctx is alive.
+	d := r.done
+	go func() {
+		select {
+		case <-ctx.Done():
+			d.close()
+		case <-d.c:
+		}
+	}()
+	return s, r
+}
+
+// done signals that the receiver stops receiving values.
+type done struct {
+	once sync.Once
+	c    chan struct{}
+}
+
+// close signals the sender, and the goroutine of RangerContext if any.
+func (d *done) close() {
+	d.once.Do(func() { close(d.c) })
+}
+
 // A sender is used to send values to a Receiver.
 type Sender[T any] struct {
 	values chan<- T
-	done <-chan bool
+	done   *done
 }
 
 // Send sends a value to the receiver. It returns whether any more
 // values may be sent; if it returns false the value was not sent.
 func (s *Sender[T]) Send(v T) bool {
+	select {
+	case <-s.done.c:
+		return false
+	default:
+	}
 	select {
 	case s.values <- v:
 		return true
-	case <-s.done:
+	case <-s.done.c:
 		return false
 	}
 }
@@ -49,18 +88,30 @@ func (s *Sender[T]) Close() {
 // A Receiver receives values from a Sender.
 type Receiver[T any] struct {
 	values <-chan T
-	done chan<- bool
+	done   *done
 }
 
 // Next returns the next value from the channel. The bool result
 // indicates whether the value is valid, or whether the Sender has
-// been closed and no more values will be received.
+// been closed, or the Receiver itself, and no more values will be
+// received.
 func (r *Receiver[T]) Next() (T, bool) {
-	v, ok := <-r.values
-	return v, ok
+	select {
+	case v, ok := <-r.values:
+		return v, ok
+	case <-r.done.c:
+		var zero T
+		return zero, false
+	}
+}
+
+// Close tells the sender that no more values will be received, so
+// that its Send returns false. Close may be called more than once.
+func (r *Receiver[T]) Close() {
+	r.done.close()
 }
 
 // finalize is a finalizer for the receiver.
 func (r *Receiver[T]) finalize() {
-	close(r.done)
+	r.done.close()
 }
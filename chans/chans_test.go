@@ -0,0 +1,88 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chans_test
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"golang.design/x/go2generics/chans"
+)
+
+func TestRanger(t *testing.T) {
+	s, r := chans.Ranger[int]()
+	go func() {
+		for i := 0; i < 3; i++ {
+			s.Send(i)
+		}
+		s.Close()
+	}()
+
+	var got []int
+	for v, ok := r.Next(); ok; v, ok = r.Next() {
+		got = append(got, v)
+	}
+	if len(got) != 3 || got[2] != 2 {
+		t.Fatalf("received %v, want [0 1 2]", got)
+	}
+}
+
+func TestRangerClose(t *testing.T) {
+	s, r := chans.Ranger[int]()
+	stopped := make(chan int)
+	go func() {
+		n := 0
+		for s.Send(n) {
+			n++
+		}
+		stopped <- n
+	}()
+
+	r.Next()
+	r.Close()
+	r.Close()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Send did not return false after the receiver was closed")
+	}
+	if _, ok := r.Next(); ok {
+		t.Fatal("Next returned a value after Close")
+	}
+}
+
+func TestRangerContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s, r := chans.RangerContext[int](ctx)
+	cancel()
+	if _, ok := r.Next(); ok {
+		t.Fatal("Next returned a value after the context was canceled")
+	}
+	if s.Send(1) {
+		t.Fatal("Send succeeded after the context was canceled")
+	}
+}
+
+func TestRangerFinalizer(t *testing.T) {
+	s, _ := chans.Ranger[int]()
+	done := make(chan struct{})
+	go func() {
+		for s.Send(0) {
+		}
+		close(done)
+	}()
+
+	for i := 0; i < 10; i++ {
+		runtime.GC()
+		select {
+		case <-done:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	t.Fatal("Send did not return false after the receiver was freed")
+}
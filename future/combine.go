@@ -0,0 +1,164 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package future
+
+import (
+	"errors"
+	"strings"
+
+	"golang.design/x/go2generics/sync/atomic"
+)
+
+// ErrNoFutures is the error of the future returned by Any or Race when
+// they are given no futures.
+var ErrNoFutures = errors.New("future: no futures")
+
+// AnyError is the error of the future returned by Any when all of its
+// futures are rejected.
+type AnyError struct {
+	// Errs are the errors of the futures, in order.
+	Errs []error
+}
+
+func (e *AnyError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Is reports whether any of the errors matches target.
+func (e *AnyError) Is(target error) bool {
+	for _, err := range e.Errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors that matches target.
+func (e *AnyError) As(target any) bool {
+	for _, err := range e.Errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Result is the outcome of a settled future.
+type Result[T any] struct {
+	Value T
+	Err   error
+}
+
+// All returns a future of the values of fs, in order. It is rejected with
+// the first error of fs, in which case the futures of fs that are not
+// settled yet are canceled.
+func All[T any](fs ...*Future[T]) *Future[[]T] {
+	all := newFuture[[]T]()
+	values := make([]T, len(fs))
+	remaining := atomic.Int[int]{}
+	remaining.Store(len(fs))
+	if len(fs) == 0 {
+		all.settle(values, nil)
+	}
+	for i, f := range fs {
+		i, f := i, f
+		f.whenDone(func() {
+			if f.err != nil {
+				all.settle(nil, f.err)
+				return
+			}
+			values[i] = f.value
+			if remaining.Add(-1) == 0 {
+				all.settle(values, nil)
+			}
+		})
+	}
+	cancelOnDone(all, fs)
+	return all
+}
+
+// AllSettled returns a future of the results of fs, in order, which is
+// resolved once all of fs are settled.
+func AllSettled[T any](fs ...*Future[T]) *Future[[]Result[T]] {
+	all := newFuture[[]Result[T]]()
+	results := make([]Result[T], len(fs))
+	remaining := atomic.Int[int]{}
+	remaining.Store(len(fs))
+	if len(fs) == 0 {
+		all.settle(results, nil)
+	}
+	for i, f := range fs {
+		i, f := i, f
+		f.whenDone(func() {
+			results[i] = Result[T]{Value: f.value, Err: f.err}
+			if remaining.Add(-1) == 0 {
+				all.settle(results, nil)
+			}
+		})
+	}
+	cancelOnDone(all, fs)
+	return all
+}
+
+// Any returns a future of the value of the first of fs to be resolved,
+// after which the others are canceled. If all of fs are rejected, the
+// future is rejected with an *AnyError of their errors.
+func Any[T any](fs ...*Future[T]) *Future[T] {
+	if len(fs) == 0 {
+		return Rejected[T](ErrNoFutures)
+	}
+	first := newFuture[T]()
+	errs := make([]error, len(fs))
+	remaining := atomic.Int[int]{}
+	remaining.Store(len(fs))
+	for i, f := range fs {
+		i, f := i, f
+		f.whenDone(func() {
+			if f.err == nil {
+				first.settle(f.value, nil)
+				return
+			}
+			errs[i] = f.err
+			if remaining.Add(-1) == 0 {
+				var zero T
+				first.settle(zero, &AnyError{Errs: errs})
+			}
+		})
+	}
+	cancelOnDone(first, fs)
+	return first
+}
+
+// Race returns a future settled like the first of fs to be settled, after
+// which the others are canceled.
+func Race[T any](fs ...*Future[T]) *Future[T] {
+	if len(fs) == 0 {
+		return Rejected[T](ErrNoFutures)
+	}
+	first := newFuture[T]()
+	for _, f := range fs {
+		f := f
+		f.whenDone(func() {
+			first.settle(f.value, f.err)
+		})
+	}
+	cancelOnDone(first, fs)
+	return first
+}
+
+// cancelOnDone cancels the futures of fs that are not settled yet once
+// the combined future is settled, whether by them or by its own Cancel.
+func cancelOnDone[T, U any](combined *Future[U], fs []*Future[T]) {
+	combined.whenDone(func() {
+		for _, f := range fs {
+			f.Cancel()
+		}
+	})
+}
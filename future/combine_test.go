@@ -0,0 +1,137 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package future
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func promises[T any](n int) ([]*Promise[T], []*Future[T]) {
+	ps := make([]*Promise[T], n)
+	fs := make([]*Future[T], n)
+	for i := range ps {
+		ps[i] = NewPromise[T]()
+		fs[i] = ps[i].Future()
+	}
+	return ps, fs
+}
+
+func canceled[T any](f *Future[T]) bool {
+	select {
+	case <-f.Done():
+		_, err := f.Get()
+		return err == ErrCanceled
+	default:
+		return false
+	}
+}
+
+func TestAll(t *testing.T) {
+	ps, fs := promises[int](3)
+	all := All(fs...)
+	ps[2].Resolve(3)
+	ps[0].Resolve(1)
+	ps[1].Resolve(2)
+	if v, err := all.Get(); !reflect.DeepEqual(v, []int{1, 2, 3}) || err != nil {
+		t.Fatalf("All = %v, %v, want [1 2 3], <nil>", v, err)
+	}
+
+	if v, err := All[int]().Get(); len(v) != 0 || err != nil {
+		t.Fatalf("All of none = %v, %v, want [], <nil>", v, err)
+	}
+}
+
+func TestAllFailFast(t *testing.T) {
+	ps, fs := promises[int](3)
+	all := All(fs...)
+	want := errors.New("failed")
+	ps[0].Resolve(1)
+	ps[1].Reject(want)
+	if _, err := all.Get(); err != want {
+		t.Fatalf("All error = %v, want %v", err, want)
+	}
+	if !canceled(fs[2]) {
+		t.Fatal("pending future was not canceled after All failed")
+	}
+	if ps[2].Resolve(3) {
+		t.Fatal("canceled future could still be resolved")
+	}
+}
+
+func TestAllSettled(t *testing.T) {
+	ps, fs := promises[int](2)
+	all := AllSettled(fs...)
+	want := errors.New("failed")
+	ps[1].Reject(want)
+	ps[0].Resolve(1)
+	v, err := all.Get()
+	if err != nil || !reflect.DeepEqual(v, []Result[int]{{Value: 1}, {Err: want}}) {
+		t.Fatalf("AllSettled = %v, %v", v, err)
+	}
+}
+
+func TestAny(t *testing.T) {
+	ps, fs := promises[int](3)
+	first := Any(fs...)
+	ps[0].Reject(errors.New("failed"))
+	ps[2].Resolve(3)
+	if v, err := first.Get(); v != 3 || err != nil {
+		t.Fatalf("Any = %v, %v, want 3, <nil>", v, err)
+	}
+	if !canceled(fs[1]) {
+		t.Fatal("loser was not canceled after Any succeeded")
+	}
+
+	e1, e2 := errors.New("e1"), errors.New("e2")
+	_, err := Any(Rejected[int](e1), Rejected[int](e2)).Get()
+	var aerr *AnyError
+	if !errors.Is(err, e1) || !errors.Is(err, e2) || !errors.As(err, &aerr) || len(aerr.Errs) != 2 {
+		t.Fatalf("Any of rejected futures = %v, want both errors", err)
+	}
+	if _, err := Any[int]().Get(); err != ErrNoFutures {
+		t.Fatalf("Any of none error = %v, want %v", err, ErrNoFutures)
+	}
+}
+
+func TestRace(t *testing.T) {
+	ps, fs := promises[int](2)
+	first := Race(fs...)
+	want := errors.New("failed")
+	ps[1].Reject(want)
+	if _, err := first.Get(); err != want {
+		t.Fatalf("Race error = %v, want %v", err, want)
+	}
+	if !canceled(fs[0]) {
+		t.Fatal("loser was not canceled after Race")
+	}
+
+	// Canceling the combined future cancels its inputs.
+	ps, fs = promises[int](2)
+	Race(fs...).Cancel()
+	if !canceled(fs[0]) || !canceled(fs[1]) {
+		t.Fatal("inputs were not canceled with the race")
+	}
+}
+
+func TestCombineNoGoroutines(t *testing.T) {
+	const n = 1000
+
+	before := runtime.NumGoroutine()
+	ps, fs := promises[int](n)
+	all := All(fs...)
+	if g := runtime.NumGoroutine(); g > before {
+		t.Fatalf("All of %d pending futures started %d goroutines", n, g-before)
+	}
+	for i, p := range ps {
+		go p.Resolve(i)
+	}
+	v, err := all.Get()
+	if err != nil || len(v) != n || v[n-1] != n-1 {
+		t.Fatalf("All = %d values, %v", len(v), err)
+	}
+}
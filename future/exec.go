@@ -0,0 +1,153 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package future
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// ErrClosed is the error of the futures submitted to a closed Executor.
+var ErrClosed = errors.New("future: executor closed")
+
+// PanicError is the error of a future whose function panicked.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("future: panic: %v\n\n%s", p.Value, p.Stack)
+}
+
+// settleFunc settles f with the results of fn, or with a *PanicError if
+// fn panics.
+func (f *Future[T]) settleFunc(fn func() (T, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			var zero T
+			f.settle(zero, &PanicError{Value: r, Stack: debug.Stack()})
+		}
+	}()
+	f.settle(fn())
+}
+
+// Go calls fn in a new goroutine and returns a future of its results.
+// The context passed to fn is canceled once the future is settled, which
+// includes a call of Cancel on the future.
+func Go[T any](ctx context.Context, fn func(ctx context.Context) (T, error)) *Future[T] {
+	f, run := task(ctx, fn)
+	go run()
+	return f
+}
+
+// task returns a future of fn and the function that runs fn to settle
+// it. fn is not called if the future is settled before.
+func task[T any](ctx context.Context, fn func(ctx context.Context) (T, error)) (*Future[T], func()) {
+	f := newFuture[T]()
+	ctx, cancel := context.WithCancel(ctx)
+	f.whenDone(cancel)
+	return f, func() {
+		select {
+		case <-f.done:
+			return
+		default:
+		}
+		f.settleFunc(func() (T, error) { return fn(ctx) })
+	}
+}
+
+// Executor runs functions in a bounded number of goroutines. Functions
+// submitted while all goroutines are busy wait in a queue.
+type Executor struct {
+	// mu orders the registration of senders and the close of closed,
+	// it is never held while a function is sent to queue.
+	mu      sync.Mutex
+	closed  chan struct{}
+	senders sync.WaitGroup
+	// queue is closed once closed is closed and all senders returned.
+	queue chan func()
+	wg    sync.WaitGroup
+}
+
+// NewExecutor returns an executor that runs at most workers functions at
+// a time and queues at most queue functions beyond that.
+func NewExecutor(workers, queue int) *Executor {
+	if workers < 1 || queue < 0 {
+		panic("future: invalid executor size")
+	}
+	e := &Executor{queue: make(chan func(), queue), closed: make(chan struct{})}
+	e.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go e.worker()
+	}
+	return e
+}
+
+func (e *Executor) worker() {
+	defer e.wg.Done()
+	for run := range e.queue {
+		run()
+	}
+}
+
+// Submit queues fn to be called by e and returns a future of its results.
+// If the queue is full, Submit blocks until there is room or ctx is done,
+// in which case the future is rejected with ctx.Err(), or e is closed,
+// in which case it is rejected with ErrClosed. The context passed to fn
+// is canceled once the future is settled, and fn is not called at all if
+// the future is canceled while it is queued.
+func Submit[T any](ctx context.Context, e *Executor, fn func(ctx context.Context) (T, error)) *Future[T] {
+	if !e.enter() {
+		return Rejected[T](ErrClosed)
+	}
+	defer e.senders.Done()
+
+	f, run := task(ctx, fn)
+	var zero T
+	select {
+	case e.queue <- run:
+	case <-e.closed:
+		f.settle(zero, ErrClosed)
+	case <-ctx.Done():
+		f.settle(zero, ctx.Err())
+	}
+	return f
+}
+
+// enter registers a sender, it reports false if e is closed.
+func (e *Executor) enter() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	select {
+	case <-e.closed:
+		return false
+	default:
+	}
+	e.senders.Add(1)
+	return true
+}
+
+// Close stops e from accepting functions and waits for the queued ones
+// to return. The functions that are waiting for room in the queue are
+// rejected with ErrClosed.
+func (e *Executor) Close() {
+	e.mu.Lock()
+	select {
+	case <-e.closed:
+		e.mu.Unlock()
+	default:
+		close(e.closed)
+		e.mu.Unlock()
+		// no sender registers after closed is closed, so the queue can
+		// be closed once the registered ones return.
+		e.senders.Wait()
+		close(e.queue)
+	}
+	e.wg.Wait()
+}
@@ -0,0 +1,193 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package future
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGo(t *testing.T) {
+	f := Go(context.Background(), func(ctx context.Context) (int, error) {
+		return 42, nil
+	})
+	if v, err := f.Get(); v != 42 || err != nil {
+		t.Fatalf("Get = %v, %v, want 42, <nil>", v, err)
+	}
+}
+
+func TestGoPanic(t *testing.T) {
+	f := Go(context.Background(), func(ctx context.Context) (int, error) {
+		panic("boom")
+	})
+	_, err := f.Get()
+	var p *PanicError
+	if !errors.As(err, &p) || p.Value != "boom" {
+		t.Fatalf("Get error = %v, want a *PanicError of boom", err)
+	}
+	if !strings.Contains(string(p.Stack), "TestGoPanic") {
+		t.Fatalf("stack does not contain the panicking function:\n%s", p.Stack)
+	}
+
+	_, err = Resolved(1).Then(func(int) (int, error) { panic("then") }).Get()
+	if !errors.As(err, &p) || p.Value != "then" {
+		t.Fatalf("Then error = %v, want a *PanicError of then", err)
+	}
+}
+
+func TestGoCancel(t *testing.T) {
+	started := make(chan struct{})
+	stopped := make(chan error)
+	f := Go(context.Background(), func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		stopped <- ctx.Err()
+		return 0, ctx.Err()
+	})
+	<-started
+	f.Cancel()
+	if err := <-stopped; err != context.Canceled {
+		t.Fatalf("context error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := f.Get(); err != ErrCanceled {
+		t.Fatalf("Get error = %v, want %v", err, ErrCanceled)
+	}
+}
+
+func TestExecutorBounded(t *testing.T) {
+	const workers = 3
+
+	e := NewExecutor(workers, 100)
+	defer e.Close()
+
+	var running, max int32
+	fs := make([]*Future[int], 50)
+	for i := range fs {
+		i := i
+		fs[i] = Submit(context.Background(), e, func(ctx context.Context) (int, error) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return i, nil
+		})
+	}
+	v, err := All(fs...).Get()
+	if err != nil || len(v) != len(fs) || v[len(v)-1] != len(fs)-1 {
+		t.Fatalf("All = %v, %v", v, err)
+	}
+	if m := atomic.LoadInt32(&max); m > workers {
+		t.Fatalf("%d functions ran at once, want at most %d", m, workers)
+	}
+}
+
+func TestExecutorQueue(t *testing.T) {
+	e := NewExecutor(1, 1)
+
+	release := make(chan struct{})
+	block := func(ctx context.Context) (int, error) {
+		<-release
+		return 1, nil
+	}
+	busy := Submit(context.Background(), e, block)
+	// Wait until the worker takes the first function, so that the
+	// second one fills the queue.
+	for len(e.queue) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	var ran int32
+	queued := Submit(context.Background(), e, func(ctx context.Context) (int, error) {
+		atomic.StoreInt32(&ran, 1)
+		return 2, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := Submit(ctx, e, block).Get(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Submit to a full queue error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// A canceled function is skipped by the worker.
+	queued.Cancel()
+	close(release)
+	if v, err := busy.Get(); v != 1 || err != nil {
+		t.Fatalf("Get = %v, %v, want 1, <nil>", v, err)
+	}
+	e.Close()
+	if atomic.LoadInt32(&ran) != 0 {
+		t.Fatal("canceled function was called")
+	}
+
+	if _, err := Submit(context.Background(), e, block).Get(); err != ErrClosed {
+		t.Fatalf("Submit after Close error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestExecutorClose(t *testing.T) {
+	e := NewExecutor(2, 10)
+	var wg sync.WaitGroup
+	var done int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Submit(context.Background(), e, func(ctx context.Context) (int, error) {
+				atomic.AddInt32(&done, 1)
+				return 0, nil
+			})
+		}()
+	}
+	wg.Wait()
+	e.Close()
+	if n := atomic.LoadInt32(&done); n != 10 {
+		t.Fatalf("Close returned after %d of 10 queued functions", n)
+	}
+}
+
+func TestExecutorCloseSubmitting(t *testing.T) {
+	e := NewExecutor(1, 1)
+
+	submitting := make(chan struct{})
+	inner := make(chan *Future[int], 1)
+	outer := Submit(context.Background(), e, func(ctx context.Context) (int, error) {
+		// The first function fills the queue, the second one waits for
+		// room, which is only made once this function returns.
+		Submit(context.Background(), e, func(ctx context.Context) (int, error) { return 1, nil })
+		close(submitting)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		inner <- Submit(ctx, e, func(ctx context.Context) (int, error) { return 2, nil })
+		return 0, nil
+	})
+	<-submitting
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		e.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close deadlocks with a Submit from a running function")
+	}
+	if _, err := outer.Get(); err != nil {
+		t.Fatalf("Get = %v, want <nil>", err)
+	}
+	if _, err := (<-inner).Get(); err != ErrClosed {
+		t.Fatalf("Submit during Close error = %v, want %v", err, ErrClosed)
+	}
+}
@@ -5,23 +5,179 @@
 package future
 
 import (
-	"runtime"
-	"sync/atomic"
+	"context"
+	"errors"
+	"sync"
 )
 
+// ErrCanceled is the error of a future that is canceled before it is
+// settled by its promise.
+var ErrCanceled = errors.New("future: canceled")
+
+// Future is the result of an asynchronous computation, which is either a
+// value or an error. A Future is created by a Promise, which settles it.
 type Future[T any] struct {
-	value atomic.Value
+	done chan struct{}
+
+	mu      sync.Mutex
+	settled bool
+	// value and err are written once before done is closed.
+	value T
+	err   error
+	// callbacks are called once the future is settled, guarded by mu.
+	callbacks []func()
+}
+
+// Promise is the writing side of a Future.
+type Promise[T any] struct {
+	f *Future[T]
+}
+
+// NewPromise returns a promise of an unsettled future.
+func NewPromise[T any]() *Promise[T] {
+	return &Promise[T]{f: newFuture[T]()}
+}
+
+func newFuture[T any]() *Future[T] {
+	return &Future[T]{done: make(chan struct{})}
+}
+
+// Resolved returns a future resolved with v.
+func Resolved[T any](v T) *Future[T] {
+	f := newFuture[T]()
+	f.settle(v, nil)
+	return f
+}
+
+// Rejected returns a future rejected with err, which must not be nil.
+func Rejected[T any](err error) *Future[T] {
+	p := NewPromise[T]()
+	p.Reject(err)
+	return p.f
+}
+
+// Future returns the future settled by the promise.
+func (p *Promise[T]) Future() *Future[T] {
+	return p.f
+}
+
+// Resolve settles the future with the value v. Only the first call of
+// Resolve or Reject settles the future, the later calls report false.
+func (p *Promise[T]) Resolve(v T) bool {
+	return p.f.settle(v, nil)
+}
+
+// Reject settles the future with the error err, which must not be nil.
+// Only the first call of Resolve or Reject settles the future, the later
+// calls report false.
+func (p *Promise[T]) Reject(err error) bool {
+	if err == nil {
+		panic("future: Reject with nil error")
+	}
+	var zero T
+	return p.f.settle(zero, err)
 }
 
-// Get implements TaskFuture interface
-func (f *Future[T]) Get() T {
-	var v any
-	for ; v == nil; v = f.value.Load() {
-		runtime.Gosched()
+func (f *Future[T]) settle(v T, err error) bool {
+	f.mu.Lock()
+	if f.settled {
+		f.mu.Unlock()
+		return false
 	}
-	return v.(T)
+	f.settled = true
+	f.value, f.err = v, err
+	callbacks := f.callbacks
+	f.callbacks = nil
+	close(f.done)
+	f.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn()
+	}
+	return true
+}
+
+// whenDone calls fn once the future is settled, immediately if it is
+// already settled. fn runs in the goroutine that settles the future, so
+// it must not block.
+func (f *Future[T]) whenDone(fn func()) {
+	f.mu.Lock()
+	if !f.settled {
+		f.callbacks = append(f.callbacks, fn)
+		f.mu.Unlock()
+		return
+	}
+	f.mu.Unlock()
+	fn()
+}
+
+// Cancel rejects the future with ErrCanceled if it is not settled yet,
+// and reports whether it did. The producer of the future learns about it
+// through the Done channel of the future.
+func (f *Future[T]) Cancel() bool {
+	var zero T
+	return f.settle(zero, ErrCanceled)
+}
+
+// Done returns a channel that is closed when the future is settled.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Get waits for the future to be settled and returns its value or error.
+func (f *Future[T]) Get() (T, error) {
+	<-f.done
+	return f.value, f.err
+}
+
+// GetContext is like Get but returns ctx.Err() if ctx is done before the
+// future is settled.
+func (f *Future[T]) GetContext(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Then returns a future of the result of fn applied to the value of f.
+// If f is rejected, fn is not called and the returned future is rejected
+// with the same error. If fn panics, the returned future is rejected with
+// a *PanicError. Then is Map for functions that keep the type, so
+// that it can be chained.
+func (f *Future[T]) Then(fn func(T) (T, error)) *Future[T] {
+	return Map(f, fn)
+}
+
+// Recover returns a future of the result of fn applied to the error of f.
+// If f is resolved, fn is not called and the returned future is resolved
+// with the same value.
+func (f *Future[T]) Recover(fn func(error) (T, error)) *Future[T] {
+	next := newFuture[T]()
+	f.whenDone(func() {
+		if f.err == nil {
+			next.settle(f.value, nil)
+			return
+		}
+		go next.settleFunc(func() (T, error) { return fn(f.err) })
+	})
+	return next
 }
 
-func (f *Future[T]) Put(v T) {
-	f.value.Store(any(v))
+// Map returns a future of the result of fn applied to the value of f.
+// If f is rejected, fn is not called and the returned future is rejected
+// with the same error.
+func Map[T, U any](f *Future[T], fn func(T) (U, error)) *Future[U] {
+	next := newFuture[U]()
+	f.whenDone(func() {
+		if f.err != nil {
+			var zero U
+			next.settle(zero, f.err)
+			return
+		}
+		go next.settleFunc(func() (U, error) { return fn(f.value) })
+	})
+	return next
 }
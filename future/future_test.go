@@ -5,20 +5,103 @@
 package future
 
 import (
+	"context"
+	"errors"
+	"strconv"
 	"testing"
 	"time"
 )
 
 func TestFuture(t *testing.T) {
 	s := "done"
-	f := Future[string]{}
+	p := NewPromise[string]()
 
 	go func() {
-		time.Sleep(time.Second)
-		f.Put(s)
+		time.Sleep(10 * time.Millisecond)
+		p.Resolve(s)
 	}()
 
-	if f.Get() != s {
-		t.Fatalf("future failed")
+	if v, err := p.Future().Get(); v != s || err != nil {
+		t.Fatalf("Get = %q, %v, want %q, <nil>", v, err, s)
+	}
+}
+
+func TestFutureFirstWins(t *testing.T) {
+	p := NewPromise[int]()
+	if !p.Resolve(1) {
+		t.Fatal("first Resolve failed")
+	}
+	if p.Resolve(2) {
+		t.Fatal("second Resolve succeeded")
+	}
+	if p.Reject(errors.New("late")) {
+		t.Fatal("Reject after Resolve succeeded")
+	}
+	if v, err := p.Future().Get(); v != 1 || err != nil {
+		t.Fatalf("Get = %v, %v, want 1, <nil>", v, err)
+	}
+
+	want := errors.New("failed")
+	p = NewPromise[int]()
+	p.Reject(want)
+	if p.Resolve(1) {
+		t.Fatal("Resolve after Reject succeeded")
+	}
+	if _, err := p.Future().Get(); err != want {
+		t.Fatalf("Get error = %v, want %v", err, want)
+	}
+}
+
+func TestFutureGetContext(t *testing.T) {
+	p := NewPromise[int]()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Future().GetContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetContext error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	p.Resolve(42)
+	if v, err := p.Future().GetContext(context.Background()); v != 42 || err != nil {
+		t.Fatalf("GetContext = %v, %v, want 42, <nil>", v, err)
+	}
+	select {
+	case <-p.Future().Done():
+	default:
+		t.Fatal("Done is not closed after Resolve")
+	}
+}
+
+func TestFutureChain(t *testing.T) {
+	p := NewPromise[int]()
+	double := func(v int) (int, error) { return v * 2, nil }
+	f := Map(p.Future().Then(double).Then(double), func(v int) (string, error) {
+		return strconv.Itoa(v), nil
+	})
+	p.Resolve(3)
+	if v, err := f.Get(); v != "12" || err != nil {
+		t.Fatalf("Get = %q, %v, want 12, <nil>", v, err)
+	}
+
+	want := errors.New("failed")
+	called := false
+	f2 := Rejected[int](want).Then(func(v int) (int, error) {
+		called = true
+		return v, nil
+	})
+	if _, err := f2.Get(); err != want || called {
+		t.Fatalf("Then on a rejected future: error %v, called %v", err, called)
+	}
+
+	r := f2.Recover(func(err error) (int, error) {
+		if err != want {
+			t.Errorf("Recover got %v, want %v", err, want)
+		}
+		return 7, nil
+	})
+	if v, err := r.Get(); v != 7 || err != nil {
+		t.Fatalf("Recover = %v, %v, want 7, <nil>", v, err)
+	}
+	if v, err := Resolved(1).Recover(func(error) (int, error) { return 0, nil }).Get(); v != 1 || err != nil {
+		t.Fatalf("Recover of a resolved future = %v, %v, want 1, <nil>", v, err)
 	}
 }
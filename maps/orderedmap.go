@@ -69,6 +69,9 @@ type keyValue[K, V any] struct {
 }
 
 // InOrder returns an iterator that does an in-order traversal of the map.
+// The traversal runs in its own goroutine, which exits once the iterator
+// reaches the end or is stopped, so Stop must be called if the iteration
+// ends early.
 func (m *OrderedMap[K, V]) InOrder() *Iterator[K, V] {
 	sender, receiver := chans.Ranger[keyValue[K, V]]()
 	var f func(*node[K, V]) bool
@@ -105,3 +108,9 @@ func (it *Iterator[K, V]) Next() (K, V, bool) {
 	}
 	return keyval.key, keyval.val, true
 }
+
+// Stop ends the iteration and the traversal. Next returns false after
+// Stop is called. Stop may be called more than once.
+func (it *Iterator[K, V]) Stop() {
+	it.r.Close()
+}
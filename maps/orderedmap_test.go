@@ -0,0 +1,59 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package maps
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func newIntMap(keys ...int) *OrderedMap[int, string] {
+	m := NewOrderedMap[int, string](func(a, b int) int { return a - b })
+	for _, k := range keys {
+		m.Insert(k, "")
+	}
+	return m
+}
+
+func TestOrderedMapInOrder(t *testing.T) {
+	m := newIntMap(5, 3, 8, 1, 4)
+	it := m.InOrder()
+	var got []int
+	for k, _, ok := it.Next(); ok; k, _, ok = it.Next() {
+		got = append(got, k)
+	}
+	want := []int{1, 3, 4, 5, 8}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestOrderedMapInOrderStop(t *testing.T) {
+	m := newIntMap(5, 3, 8, 1, 4)
+	before := runtime.NumGoroutine()
+
+	it := m.InOrder()
+	if k, _, ok := it.Next(); !ok || k != 1 {
+		t.Fatalf("Next = %v, %v, want 1, true", k, ok)
+	}
+	it.Stop()
+	if _, _, ok := it.Next(); ok {
+		t.Fatal("Next returned a pair after Stop")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatal("traversal goroutine did not exit after Stop")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
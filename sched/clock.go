@@ -0,0 +1,48 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sched
+
+import "time"
+
+// Clock is the source of time of a scheduler.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewTimer creates a timer that fires after d.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a timer created by a Clock, it behaves like time.Timer.
+type Timer interface {
+	// C returns the channel on which the time is delivered.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing, it reports whether the
+	// timer was active.
+	Stop() bool
+	// Reset changes the timer to fire after d, it reports whether the
+	// timer was active.
+	Reset(d time.Duration) bool
+}
+
+// WithClock sets the clock of a scheduler, the default clock is the
+// system clock of package time.
+func WithClock(c Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// systemClock is a Clock of package time.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct{ *time.Timer }
+
+func (t systemTimer) C() <-chan time.Time { return t.Timer.C }
@@ -0,0 +1,187 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sched
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule describes the occurrences of a recurring task.
+type Schedule interface {
+	// Next returns the first occurrence strictly after t, or the zero
+	// time if there is no more occurrence.
+	Next(t time.Time) time.Time
+}
+
+// Every returns a schedule that occurs every d.
+func Every(d time.Duration) Schedule {
+	return every(d)
+}
+
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// cron is a schedule of a standard 5-field cron expression, each
+// field is a bit set of the allowed values.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record if the day fields are unrestricted,
+	// if both are restricted, a day matches either of them.
+	domStar, dowStar bool
+}
+
+// cronField is the range of a field of a cron expression.
+type cronField struct {
+	name     string
+	min, max uint
+}
+
+var cronFields = [...]cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a standard 5-field cron expression:
+//
+//	minute hour day-of-month month day-of-week
+//
+// Each field is either *, a number, a range a-b, or a list of them
+// separated by commas, and optionally followed by a step /n. Day of
+// week 0 and 7 are both Sunday. The macros @yearly, @annually,
+// @monthly, @weekly, @daily, @midnight and @hourly are supported.
+// Occurrences are computed in the location of the given time.
+func ParseCron(expr string) (Schedule, error) {
+	if m, ok := cronMacros[strings.TrimSpace(expr)]; ok {
+		expr = m
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("sched: cron expression %q must have %d fields", expr, len(cronFields))
+	}
+
+	var bits [len(cronFields)]uint64
+	for i, f := range fields {
+		b, err := parseCronField(f, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("sched: cron expression %q: %w", expr, err)
+		}
+		bits[i] = b
+	}
+	c := &cron{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}
+	// Sunday is both 0 and 7
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+func parseCronField(s string, f cronField) (bits uint64, err error) {
+	for _, part := range strings.Split(s, ",") {
+		rng, step := part, uint(1)
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.ParseUint(part[i+1:], 10, 8)
+			if err != nil || n == 0 {
+				return 0, fmt.Errorf("invalid step in %s field: %q", f.name, part)
+			}
+			rng, step = part[:i], uint(n)
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			i := strings.IndexByte(rng, '-')
+			if lo, err = parseCronValue(rng[:i], f); err != nil {
+				return 0, err
+			}
+			if hi, err = parseCronValue(rng[i+1:], f); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range in %s field: %q", f.name, rng)
+			}
+		default:
+			if lo, err = parseCronValue(rng, f); err != nil {
+				return 0, err
+			}
+			// a single value with a step means from the value to max
+			if step == 1 {
+				hi = lo
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func parseCronValue(s string, f cronField) (uint, error) {
+	n, err := strconv.ParseUint(s, 10, 8)
+	if err != nil || uint(n) < f.min || uint(n) > f.max {
+		return 0, fmt.Errorf("invalid value in %s field: %q", f.name, s)
+	}
+	return uint(n), nil
+}
+
+func (c *cron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+
+	// an expression never matches if it only allows e.g. Feb 30,
+	// so give up after a few years.
+	for limit := t.AddDate(5, 0, 0); t.Before(limit); {
+		y, m, d := t.Date()
+		switch {
+		case c.month&(1<<uint(m)) == 0:
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !c.matchDay(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c *cron) matchDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
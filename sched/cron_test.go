@@ -0,0 +1,168 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sched
+
+import (
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	start := time.Date(2021, time.August, 30, 10, 17, 42, 0, time.UTC) // Monday
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2021, 8, 30, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, 8, 30, 10, 30, 0, 0, time.UTC)},
+		{"5 * * * *", time.Date(2021, 8, 30, 11, 5, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2021, 8, 30, 13, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 8 * * 0", time.Date(2021, 9, 5, 8, 30, 0, 0, time.UTC)},
+		{"30 8 * * 7", time.Date(2021, 9, 5, 8, 30, 0, 0, time.UTC)},
+		{"0 0 15 * 3", time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 29 2 *", time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC)},
+		{"1,2,3 0 * 1 *", time.Date(2022, 1, 1, 0, 1, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2021, 8, 30, 11, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q) failed: %v", tt.expr, err)
+		}
+		if got := c.Next(start); !got.Equal(tt.want) {
+			t.Errorf("ParseCron(%q).Next() = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) want error", expr)
+		}
+	}
+}
+
+func TestSchedRecurring(t *testing.T) {
+	sched0 := NewSched[int, *FuncTask[int]](WithRetryPolicy(MaxAttempts(1, Fixed(0))))
+	defer sched0.Stop()
+
+	errOdd := errors.New("odd")
+	var n int64
+	stream := sched0.SubmitRecurring(NewFuncTask("tick", time.Time{}, func() (int, bool, error) {
+		i := int(atomic.AddInt64(&n, 1))
+		if i%2 == 1 {
+			return 0, false, errOdd
+		}
+		return i, false, nil
+	}), Every(5*time.Millisecond))
+
+	var last time.Time
+	for i := 1; i <= 4; i++ {
+		r := <-stream.C()
+		if i%2 == 1 && !errors.Is(r.Err, errOdd) || i%2 == 0 && r.Value != i {
+			t.Fatalf("unexpected result of occurrence %d: %+v", i, r)
+		}
+		if !r.Time.After(last) {
+			t.Fatalf("occurrences are not in order: %v after %v", r.Time, last)
+		}
+		last = r.Time
+	}
+	for !sched0.Cancel("tick") {
+		<-stream.C()
+	}
+	for range stream.C() {
+	}
+}
+
+// submitUnread submits a recurring task of every millisecond whose
+// stream is never received, and waits until the second result is
+// waiting for the receiver.
+func submitUnread(t *testing.T, sched0 Scheduler[int, *FuncTask[int]]) *Stream[int] {
+	executed := make(chan struct{}, 2)
+	var n int64
+	stream := sched0.SubmitRecurring(NewFuncTask("tick", time.Time{}, func() (int, bool, error) {
+		select {
+		case executed <- struct{}{}:
+		default:
+		}
+		return int(atomic.AddInt64(&n, 1)), false, nil
+	}), Every(time.Millisecond))
+
+	// the first result fills the stream, the second one waits.
+	<-executed
+	<-executed
+	for sched0.Stats().Running != 0 {
+		runtime.Gosched()
+	}
+	return stream
+}
+
+// stopWithin fails the test if Stop does not return within d.
+func stopWithin(t *testing.T, sched0 Scheduler[int, *FuncTask[int]], d time.Duration) {
+	stopped := make(chan struct{})
+	go func() {
+		sched0.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(d):
+		t.Fatalf("Stop hangs on an unread recurring task")
+	}
+}
+
+// checkCanceled checks that the stream has its first result and ends.
+func checkCanceled(t *testing.T, stream *Stream[int]) {
+	if r, ok := <-stream.C(); !ok || r.Value != 1 {
+		t.Fatalf("unexpected first result: %+v, %v", r, ok)
+	}
+	if _, ok := <-stream.C(); ok {
+		t.Fatalf("stream is not closed after cancel")
+	}
+}
+
+func TestSchedRecurringCancelUnread(t *testing.T) {
+	sched0 := NewSched[int, *FuncTask[int]]()
+	stream := submitUnread(t, sched0)
+	f, ok := sched0.Lookup("tick")
+	if !ok {
+		t.Fatalf("recurring task is not known")
+	}
+
+	if !sched0.Cancel("tick") {
+		t.Fatalf("cancel of an unread recurring task failed")
+	}
+	if _, err := f.GetTimeout(time.Second); !errors.Is(err, ErrCanceled) {
+		t.Fatalf("canceled future want ErrCanceled, got: %v", err)
+	}
+	stopWithin(t, sched0, time.Second)
+	checkCanceled(t, stream)
+}
+
+func TestSchedRecurringStop(t *testing.T) {
+	sched0 := NewSched[int, *FuncTask[int]]()
+	stream := submitUnread(t, sched0)
+	stopWithin(t, sched0, time.Second)
+	checkCanceled(t, stream)
+}
+
+func TestSchedRecurringWorkers(t *testing.T) {
+	sched0 := NewSched[int, *FuncTask[int]](WithWorkers(1))
+	stream := submitUnread(t, sched0)
+
+	// the only worker is not held by the unread stream
+	f := sched0.Submit(NewFuncTask("once", time.Now(), func() (int, bool, error) {
+		return 42, false, nil
+	}))
+	if v, err := f.GetTimeout(time.Second); err != nil || v != 42 {
+		t.Fatalf("task did not run beside an unread stream: %v, %v", v, err)
+	}
+	stopWithin(t, sched0, time.Second)
+	checkCanceled(t, stream)
+}
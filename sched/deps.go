@@ -0,0 +1,251 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sched
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrDependencyCycle is the error of a task that depends on itself,
+// directly or through other tasks.
+var ErrDependencyCycle = errors.New("sched: dependency cycle")
+
+// PriorityTask is an optional interface of a Task. Among the tasks
+// that are due at the same time, the tasks of higher priority are
+// executed first. A task without priority has priority zero.
+type PriorityTask interface {
+	GetPriority() int
+}
+
+// DependentTask is an optional interface of a Task. A dependent task
+// only runs after the futures of all its dependencies are resolved
+// successfully, and it fails with a *DependencyError as soon as one of
+// its dependencies fails.
+//
+// The dependencies are looked up when the task is submitted and again
+// when it is due. While the task is unfinished, the scheduler remembers
+// the failures of its dependencies, hence a dependency that is
+// submitted later and fails before the task is due fails the task as
+// well. A dependency that the scheduler does not know at both times,
+// e.g. it was never submitted or it has finished before the task is
+// submitted, is considered satisfied. The tasks of a dependency cycle fail with a
+// *DependencyError of ErrDependencyCycle once they are all due.
+type DependentTask interface {
+	GetDependencies() (ids []string)
+}
+
+// DependencyError is the error of a task whose dependency fails.
+type DependencyError struct {
+	// ID is the ID of the dependent task.
+	ID string
+	// Dependency is the ID of the failed dependency.
+	Dependency string
+	// Err is the error of the dependency.
+	Err error
+}
+
+func (e *DependencyError) Error() string {
+	return fmt.Sprintf("sched: dependency %s of task %s failed: %v", e.Dependency, e.ID, e.Err)
+}
+
+func (e *DependencyError) Unwrap() error {
+	return e.Err
+}
+
+// priorityOf returns the priority of a task
+func priorityOf[R any](t Task[R]) int {
+	if pt, ok := t.(PriorityTask); ok {
+		return pt.GetPriority()
+	}
+	return 0
+}
+
+// track records the future of a task that is newly scheduled, and
+// captures the futures of its dependencies.
+func (s *sched[R, T]) track(t *task[R]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.futures[t.value.GetID()] = t.future
+	delete(s.failures, t.value.GetID())
+	if t.stream != nil {
+		s.streams[t.value.GetID()] = t.stream
+	}
+
+	dt, ok := t.value.(DependentTask)
+	if !ok {
+		return
+	}
+	t.deps = map[string]*Future[R]{}
+	t.needs = append([]string(nil), dt.GetDependencies()...)
+	for _, id := range t.needs {
+		s.dependents[id]++
+		if f, ok := s.futures[id]; ok {
+			t.deps[id] = f
+		}
+	}
+}
+
+// untrack forgets the future of a finished task, and remembers its
+// error if it failed and an unfinished task depends on it.
+func (s *sched[R, T]) untrack(t *task[R], err error) {
+	s.mu.Lock()
+	id := t.value.GetID()
+	if s.futures[id] == t.future {
+		delete(s.futures, id)
+		if err != nil && s.dependents[id] > 0 {
+			s.failures[id] = err
+		}
+	}
+	if t.stream != nil && s.streams[id] == t.stream {
+		delete(s.streams, id)
+	}
+	// failures are only kept for the unfinished dependent tasks
+	for _, dep := range t.needs {
+		if s.dependents[dep]--; s.dependents[dep] == 0 {
+			delete(s.dependents, dep)
+			delete(s.failures, dep)
+		}
+	}
+	t.needs = nil
+	s.mu.Unlock()
+}
+
+// dependencies returns the unresolved futures of the dependencies of a
+// task, or an error if one of them fails.
+func (s *sched[R, T]) dependencies(t *task[R]) (wait []*Future[R], err error) {
+	dt, ok := t.value.(DependentTask)
+	if !ok {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	for _, id := range dt.GetDependencies() {
+		if _, ok := t.deps[id]; ok {
+			continue
+		}
+		if f, ok := s.futures[id]; ok {
+			if t.deps == nil {
+				t.deps = map[string]*Future[R]{}
+			}
+			t.deps[id] = f
+			continue
+		}
+		if err, ok := s.failures[id]; ok {
+			s.mu.Unlock()
+			return nil, &DependencyError{ID: t.value.GetID(), Dependency: id, Err: err}
+		}
+	}
+	s.mu.Unlock()
+
+	for id, f := range t.deps {
+		select {
+		case <-f.Done():
+			if f.Err != nil {
+				return nil, &DependencyError{ID: t.value.GetID(), Dependency: id, Err: f.Err}
+			}
+		default:
+			wait = append(wait, f)
+		}
+	}
+	return wait, nil
+}
+
+// park holds a task until the given futures are resolved or one of them
+// fails, then the task is rescheduled immediately to check its
+// dependencies again. A parked task can be canceled.
+func (s *sched[R, T]) park(t *task[R], wait []*Future[R]) {
+	s.mu.Lock()
+	if dep, ok := s.cycleLocked(t); ok {
+		s.mu.Unlock()
+		var zero R
+		s.complete(t, zero, &DependencyError{ID: t.value.GetID(), Dependency: dep, Err: ErrDependencyCycle})
+		return
+	}
+	t.unpark = make(chan struct{})
+	s.parked[t.value.GetID()] = t
+	s.mu.Unlock()
+
+	// a parked task stays active until it goes back to the queue
+	s.active.Add(1)
+	go func() {
+		defer s.release()
+
+		cases := make([]reflect.SelectCase, 0, len(wait)+1)
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(t.unpark)})
+		for _, f := range wait {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(f.Done())})
+		}
+		for len(cases) > 1 {
+			i, _, _ := reflect.Select(cases)
+			if i == 0 {
+				return // canceled
+			}
+			if wait[i-1].Err != nil {
+				break // fail fast
+			}
+			cases = append(cases[:i], cases[i+1:]...)
+			wait = append(wait[:i-1], wait[i:]...)
+		}
+
+		if s.unpark(t.value.GetID()) == nil {
+			return // canceled
+		}
+		s.reschedule(t, s.opts.clock.Now())
+	}()
+}
+
+// cycleLocked returns the dependency of a task through which the task
+// waits for itself, or false if there is none. A cycle is complete
+// when its last task parks, hence only parked tasks are followed.
+func (s *sched[R, T]) cycleLocked(t *task[R]) (dep string, ok bool) {
+	seen := map[*task[R]]bool{t: true}
+	var reaches func(p *task[R]) bool
+	reaches = func(p *task[R]) bool {
+		for id, f := range p.deps {
+			if f == t.future {
+				return true
+			}
+			q, ok := s.parked[id]
+			if !ok || q.future != f || seen[q] {
+				continue
+			}
+			seen[q] = true
+			if reaches(q) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for id, f := range t.deps {
+		if f == t.future {
+			return id, true
+		}
+		q, ok := s.parked[id]
+		if !ok || q.future != f || seen[q] {
+			continue
+		}
+		seen[q] = true
+		if reaches(q) {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// unpark removes a parked task by its id.
+func (s *sched[R, T]) unpark(id string) *task[R] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.parked[id]
+	if !ok {
+		return nil
+	}
+	delete(s.parked, id)
+	close(t.unpark)
+	return t
+}
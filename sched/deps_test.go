@@ -0,0 +1,219 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sched
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// FlowTask is a FuncTask with priority and dependencies
+type FlowTask struct {
+	*FuncTask[string]
+	priority int
+	deps     []string
+}
+
+// GetPriority returns the priority of the task
+func (t FlowTask) GetPriority() int { return t.priority }
+
+// GetDependencies returns the dependencies of the task
+func (t FlowTask) GetDependencies() []string { return t.deps }
+
+func TestSchedPriority(t *testing.T) {
+	sched0 := NewSched[string, FlowTask](WithWorkers(1))
+	defer sched0.Stop()
+
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	now := time.Now()
+	newTask := func(id string, priority int) FlowTask {
+		return FlowTask{FuncTask: NewFuncTask(id, now, func() (string, bool, error) {
+			mu.Lock()
+			order = append(order, id)
+			mu.Unlock()
+			return id, false, nil
+		}), priority: priority}
+	}
+
+	sched0.Pause()
+	futures := []*Future[string]{
+		sched0.Submit(newTask("low", -1)),
+		sched0.Submit(newTask("none", 0)),
+		sched0.Submit(newTask("high", 10)),
+		sched0.Submit(newTask("medium", 5)),
+	}
+	sched0.Resume()
+	for _, f := range futures {
+		f.Get()
+	}
+	if want := []string{"high", "medium", "none", "low"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("unexpected execution order: %v, want %v", order, want)
+	}
+}
+
+func TestSchedDependencies(t *testing.T) {
+	sched0 := NewSched[string, FlowTask](WithRetryPolicy(MaxAttempts(1, Fixed(0))))
+	defer sched0.Stop()
+
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	errFail := errors.New("failure")
+	newTask := func(id string, d time.Duration, fail bool, deps ...string) FlowTask {
+		return FlowTask{FuncTask: NewFuncTask(id, time.Now().Add(d), func() (string, bool, error) {
+			mu.Lock()
+			order = append(order, id)
+			mu.Unlock()
+			if fail {
+				return "", false, errFail
+			}
+			return id, false, nil
+		}), deps: deps}
+	}
+
+	extract := sched0.Submit(newTask("extract", 30*time.Millisecond, false))
+	transform := sched0.Submit(newTask("transform", 0, false, "extract", "unknown"))
+	load := sched0.Submit(newTask("load", 0, false, "transform"))
+	sched0.Submit(newTask("broken", 10*time.Millisecond, true))
+	report := sched0.Submit(newTask("report", 0, false, "broken", "extract"))
+	sched0.Submit(newTask("never", time.Hour, false))
+	waiting := sched0.Submit(newTask("waiting", 0, false, "never"))
+
+	for _, f := range []*Future[string]{extract, transform, load} {
+		if _, err := f.GetTimeout(time.Second); err != nil {
+			t.Fatalf("task failed: %v", err)
+		}
+	}
+	mu.Lock()
+	if !reflect.DeepEqual(order[len(order)-3:], []string{"extract", "transform", "load"}) {
+		t.Fatalf("dependencies are not respected: %v", order)
+	}
+	mu.Unlock()
+
+	var derr *DependencyError
+	_, err := report.GetTimeout(time.Second)
+	if !errors.As(err, &derr) || derr.Dependency != "broken" || !errors.Is(err, errFail) {
+		t.Fatalf("want a DependencyError of broken, got: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if !sched0.Cancel("waiting") {
+		t.Fatalf("cancel of a parked task failed")
+	}
+	if _, err := waiting.GetTimeout(time.Second); !errors.Is(err, ErrCanceled) {
+		t.Fatalf("want ErrCanceled, got: %v", err)
+	}
+	sched0.Cancel("never")
+	mu.Lock()
+	defer mu.Unlock()
+	for _, id := range order {
+		if id == "report" || id == "waiting" {
+			t.Fatalf("task %s should not run: %v", id, order)
+		}
+	}
+}
+
+func TestSchedFailedDependency(t *testing.T) {
+	sched0 := NewSched[string, FlowTask](WithRetryPolicy(MaxAttempts(1, Fixed(0))))
+	defer sched0.Stop()
+
+	errFail := errors.New("failure")
+	newTask := func(id string, err error, deps ...string) FlowTask {
+		return FlowTask{FuncTask: NewFuncTask(id, time.Now(), func() (string, bool, error) {
+			return id, false, err
+		}), deps: deps}
+	}
+
+	// b is submitted before a, and a fails before b is due.
+	b := sched0.Submit(FlowTask{FuncTask: NewFuncTask("b", time.Now().Add(50*time.Millisecond), func() (string, bool, error) {
+		return "b", false, nil
+	}), deps: []string{"a"}})
+	a := sched0.Submit(newTask("a", errFail))
+	if _, err := a.GetTimeout(time.Second); !errors.Is(err, errFail) {
+		t.Fatalf("want failure, got: %v", err)
+	}
+	var derr *DependencyError
+	if _, err := b.GetTimeout(time.Second); !errors.As(err, &derr) || derr.Dependency != "a" || !errors.Is(err, errFail) {
+		t.Fatalf("want a DependencyError of a, got: %v", err)
+	}
+
+	// a failure is forgotten once the task is scheduled again.
+	a = sched0.Submit(newTask("a", nil))
+	if _, err := a.GetTimeout(time.Second); err != nil {
+		t.Fatalf("task failed: %v", err)
+	}
+	c := sched0.Submit(newTask("c", nil, "a"))
+	if _, err := c.GetTimeout(time.Second); err != nil {
+		t.Fatalf("dependency of a succeeded task failed: %v", err)
+	}
+}
+
+func TestSchedFailuresBounded(t *testing.T) {
+	sched0 := NewSched[string, FlowTask](WithRetryPolicy(MaxAttempts(1, Fixed(0))))
+	defer sched0.Stop()
+
+	errFail := errors.New("failure")
+	for i := 0; i < 10000; i++ {
+		id := fmt.Sprintf("task-%d", i)
+		f := sched0.Submit(FlowTask{FuncTask: NewFuncTask(id, time.Now().Add(time.Hour), func() (string, bool, error) {
+			return id, false, nil
+		})})
+		if i%2 == 0 {
+			sched0.Cancel(id)
+		} else {
+			f = sched0.Submit(FlowTask{FuncTask: NewFuncTask(id, time.Now(), func() (string, bool, error) {
+				return id, false, errFail
+			})})
+		}
+		f.Get()
+	}
+
+	s := sched0.(*sched[string, FlowTask])
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.failures) != 0 || len(s.dependents) != 0 {
+		t.Fatalf("failures of tasks without dependents are kept: %d, %d", len(s.failures), len(s.dependents))
+	}
+}
+
+func TestSchedDependencyCycle(t *testing.T) {
+	sched0 := NewSched[string, FlowTask](WithRetryPolicy(MaxAttempts(1, Fixed(0))))
+	defer sched0.Stop()
+
+	newTask := func(id string, deps ...string) FlowTask {
+		return FlowTask{FuncTask: NewFuncTask(id, time.Now(), func() (string, bool, error) {
+			t.Errorf("task %s of a cycle was executed", id)
+			return id, false, nil
+		}), deps: deps}
+	}
+	sched0.Pause()
+	futures := []*Future[string]{
+		sched0.Submit(newTask("a", "b")),
+		sched0.Submit(newTask("b", "c")),
+		sched0.Submit(newTask("c", "a")),
+		sched0.Submit(newTask("self", "self")),
+	}
+	sched0.Resume()
+
+	for _, f := range futures {
+		var derr *DependencyError
+		if _, err := f.GetTimeout(time.Second); !errors.As(err, &derr) || !errors.Is(err, ErrDependencyCycle) {
+			t.Fatalf("want a DependencyError of a cycle, got: %v", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := sched0.Drain(ctx); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+}
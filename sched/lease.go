@@ -0,0 +1,184 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sched
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrLeaseHeld is returned by Leaser.Acquire if another owner holds
+	// an unexpired lease of the task.
+	ErrLeaseHeld = errors.New("sched: lease is held by another owner")
+	// ErrLeaseDone is returned by Leaser.Acquire if the task is already
+	// done by an owner. It is also the error of a future whose task is
+	// executed by another scheduler.
+	ErrLeaseDone = errors.New("sched: task is done by another owner")
+	// ErrLeaseNotHeld is returned by Leaser.Release if the owner does
+	// not hold the lease, e.g. the lease has expired and is acquired
+	// by another owner.
+	ErrLeaseNotHeld = errors.New("sched: lease is not held by the owner")
+)
+
+// Leaser grants exclusive leases of tasks to scheduler instances, so
+// that several schedulers sharing the same tasks execute each task
+// exactly once. A lease expires after its ttl, so that a task held by
+// a dead instance is recovered by the others.
+type Leaser interface {
+	// Acquire acquires the lease of a task for owner until ttl. It
+	// returns ErrLeaseHeld or ErrLeaseDone if the task is held or done
+	// by an owner. An owner can acquire its own lease again.
+	Acquire(id, owner string, ttl time.Duration) error
+	// Release releases the lease of a task held by owner. If done is
+	// true, the task is marked as done and cannot be acquired until the
+	// mark expires.
+	Release(id, owner string, done bool) error
+}
+
+// WithLeaser makes a scheduler execute a due task only if it acquires
+// the lease of the task from l on behalf of owner. A task held by
+// another owner is rescheduled after ttl, in case the owner dies, and
+// a task done by another owner resolves its future with ErrLeaseDone.
+//
+// The lease is not renewed while a task executes, so ttl must be
+// longer than the execution time of tasks. Each occurrence of a
+// recurring task is leased separately.
+func WithLeaser(l Leaser, owner string, ttl time.Duration) Option {
+	return func(o *options) {
+		o.leaser = l
+		o.owner = owner
+		o.ttl = ttl
+	}
+}
+
+// lease is the state of the lease of a task. Expires is the expiration
+// of the lease of Owner, or of the done mark if Done is true.
+type lease struct {
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+	Done    bool      `json:"done"`
+}
+
+func (l *lease) acquire(owner string, ttl time.Duration, now time.Time) error {
+	switch {
+	case !now.Before(l.Expires):
+		// the lease or the done mark is expired
+	case l.Done:
+		return ErrLeaseDone
+	case l.Owner != "" && l.Owner != owner:
+		return ErrLeaseHeld
+	}
+	l.Owner, l.Expires, l.Done = owner, now.Add(ttl), false
+	return nil
+}
+
+func (l *lease) release(owner string, done bool, doneTTL time.Duration, now time.Time) error {
+	if l.Done || l.Owner != owner {
+		return ErrLeaseNotHeld
+	}
+	l.Owner, l.Expires, l.Done = "", time.Time{}, done
+	if done {
+		l.Expires = now.Add(doneTTL)
+	}
+	return nil
+}
+
+// expired reports whether the lease is neither held nor done at now,
+// in which case it can be discarded.
+func (l *lease) expired(now time.Time) bool {
+	return !now.Before(l.Expires)
+}
+
+// MemoryLeaser is a Leaser for schedulers in the same process.
+type MemoryLeaser struct {
+	mu      sync.Mutex
+	clock   Clock
+	doneTTL time.Duration
+	leases  map[string]*lease
+	// sweep is the time to discard the expired leases
+	sweep time.Time
+}
+
+// NewMemoryLeaser returns a MemoryLeaser that expires leases by clock,
+// a nil clock is the system clock. A task that is done cannot be
+// acquired for doneTTL, which must be longer than the ttl of the leases
+// so that the schedulers that are late to the task see it done.
+func NewMemoryLeaser(clock Clock, doneTTL time.Duration) *MemoryLeaser {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	return &MemoryLeaser{clock: clock, doneTTL: doneTTL, leases: map[string]*lease{}}
+}
+
+// Acquire implements Leaser.
+func (m *MemoryLeaser) Acquire(id, owner string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.clock.Now()
+	if !now.Before(m.sweep) {
+		for id, l := range m.leases {
+			if l.expired(now) {
+				delete(m.leases, id)
+			}
+		}
+		m.sweep = now.Add(m.doneTTL)
+	}
+
+	l, ok := m.leases[id]
+	if !ok {
+		l = &lease{}
+		m.leases[id] = l
+	}
+	return l.acquire(owner, ttl, now)
+}
+
+// Release implements Leaser.
+func (m *MemoryLeaser) Release(id, owner string, done bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.leases[id]
+	if !ok {
+		return ErrLeaseNotHeld
+	}
+	now := m.clock.Now()
+	if err := l.release(owner, done, m.doneTTL, now); err != nil {
+		return err
+	}
+	if l.expired(now) {
+		delete(m.leases, id)
+	}
+	return nil
+}
+
+// lease acquires the lease of a due task. If the lease is not acquired,
+// the task is rescheduled or completed and ok is false, otherwise the
+// returned release must be called after the execution.
+func (s *sched[R, T]) lease(t *task[R]) (release func(done bool), ok bool) {
+	l := s.opts.leaser
+	if l == nil {
+		return func(bool) {}, true
+	}
+
+	id := t.value.GetID()
+	if t.stream != nil {
+		id = fmt.Sprintf("%s@%d", id, t.occurrence.UnixNano())
+	}
+	err := l.Acquire(id, s.opts.owner, s.opts.ttl)
+	switch {
+	case err == nil:
+		return func(done bool) { l.Release(id, s.opts.owner, done) }, true
+	case errors.Is(err, ErrLeaseDone):
+		var zero R
+		s.complete(t, zero, ErrLeaseDone)
+	default:
+		// the lease is held by another owner, or the leaser fails,
+		// try again when the lease expires.
+		s.reschedule(t, s.opts.clock.Now().Add(s.opts.ttl))
+	}
+	return nil, false
+}
@@ -0,0 +1,162 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package sched
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// FileLeaser is a Leaser for schedulers in different processes that
+// share a directory. The lease of each task is a file in the directory
+// guarded by flock(2), which is removed once the lease expires.
+type FileLeaser struct {
+	dir     string
+	clock   Clock
+	doneTTL time.Duration
+
+	mu sync.Mutex
+	// sweep is the time to remove the files of the expired leases
+	sweep time.Time
+}
+
+// NewFileLeaser returns a FileLeaser that keeps leases in dir and
+// expires them by clock, a nil clock is the system clock. A task that
+// is done cannot be acquired for doneTTL, see NewMemoryLeaser.
+func NewFileLeaser(dir string, clock Clock, doneTTL time.Duration) (*FileLeaser, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if clock == nil {
+		clock = systemClock{}
+	}
+	return &FileLeaser{dir: dir, clock: clock, doneTTL: doneTTL}, nil
+}
+
+// Acquire implements Leaser.
+func (f *FileLeaser) Acquire(id, owner string, ttl time.Duration) error {
+	f.sweepExpired()
+	return f.update(id, func(l *lease, now time.Time) error {
+		return l.acquire(owner, ttl, now)
+	})
+}
+
+// Release implements Leaser.
+func (f *FileLeaser) Release(id, owner string, done bool) error {
+	return f.update(id, func(l *lease, now time.Time) error {
+		return l.release(owner, done, f.doneTTL, now)
+	})
+}
+
+// sweepExpired removes the files of the expired leases once per
+// doneTTL, errors are ignored as the files are removed by the next
+// sweep or update.
+func (f *FileLeaser) sweepExpired() {
+	now := f.clock.Now()
+	f.mu.Lock()
+	if now.Before(f.sweep) {
+		f.mu.Unlock()
+		return
+	}
+	f.sweep = now.Add(f.doneTTL)
+	f.mu.Unlock()
+
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, ".lease") {
+			continue
+		}
+		if id, err := url.PathUnescape(strings.TrimSuffix(name, ".lease")); err == nil {
+			f.update(id, func(*lease, time.Time) error { return nil })
+		}
+	}
+}
+
+// update applies fn to the lease of a task while holding the file lock,
+// the lease is written back only if fn succeeds, and the file is
+// removed if the lease is expired.
+func (f *FileLeaser) update(id string, fn func(l *lease, now time.Time) error) error {
+	path := filepath.Join(f.dir, url.PathEscape(id)+".lease")
+	file, err := f.lock(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	var l lease
+	b, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &l); err != nil {
+			return err
+		}
+	}
+	now := f.clock.Now()
+	if err := fn(&l, now); err != nil {
+		return err
+	}
+	if l.expired(now) {
+		// the file is removed with the lock held, lock checks that
+		// the others do not lock the removed file.
+		return os.Remove(path)
+	}
+
+	if b, err = json.Marshal(l); err != nil {
+		return err
+	}
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := file.WriteAt(b, 0); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// lock opens and locks the file of path. The file may be removed by
+// another leaser between the open and the lock, in which case it is
+// opened again.
+func (f *FileLeaser) lock(path string) (*os.File, error) {
+	for {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		var locked, current syscall.Stat_t
+		if err := syscall.Fstat(int(file.Fd()), &locked); err != nil {
+			file.Close()
+			return nil, err
+		}
+		err = syscall.Stat(path, &current)
+		if err == nil && locked.Dev == current.Dev && locked.Ino == current.Ino {
+			return file, nil
+		}
+		file.Close()
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+}
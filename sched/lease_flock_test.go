@@ -0,0 +1,58 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package sched
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileLeaser(t *testing.T) {
+	dir := t.TempDir()
+	c := &manualClock{now: time.Now()}
+	l, err := NewFileLeaser(dir, c, doneTTL)
+	if err != nil {
+		t.Fatalf("create leaser failed: %v", err)
+	}
+	testLeaser(t, l, c)
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("files of expired leases are kept: %v", files)
+	}
+
+	// leasers of the same directory share leases
+	l.Acquire("task", "a", time.Hour)
+	l.Release("task", "a", true)
+	l2, _ := NewFileLeaser(dir, c, doneTTL)
+	if err := l2.Acquire("task", "c", time.Hour); !errors.Is(err, ErrLeaseDone) {
+		t.Fatalf("want ErrLeaseDone, got: %v", err)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		acquired int
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(owner string) {
+			defer wg.Done()
+			l, _ := NewFileLeaser(dir, nil, doneTTL)
+			if l.Acquire("a/b", owner, time.Hour) == nil {
+				mu.Lock()
+				acquired++
+				mu.Unlock()
+			}
+		}(string(rune('a' + i)))
+	}
+	wg.Wait()
+	if acquired != 1 {
+		t.Fatalf("lease is acquired by %d owners", acquired)
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sched
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// doneTTL is the ttl of done marks in the tests
+const doneTTL = time.Hour
+
+// manualClock is a Clock without timers whose time is moved by tests.
+type manualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) NewTimer(time.Duration) Timer {
+	panic("manualClock: no timers")
+}
+
+func (c *manualClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// testLeaser tests a leaser whose done marks expire after doneTTL by c,
+// all leases are expired when it returns.
+func testLeaser(t *testing.T, l Leaser, c *manualClock) {
+	if err := l.Acquire("task", "a", time.Hour); err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+	if err := l.Acquire("task", "a", time.Hour); err != nil {
+		t.Fatalf("acquire by the owner failed: %v", err)
+	}
+	if err := l.Acquire("task", "b", time.Hour); !errors.Is(err, ErrLeaseHeld) {
+		t.Fatalf("want ErrLeaseHeld, got: %v", err)
+	}
+	if err := l.Release("task", "b", true); !errors.Is(err, ErrLeaseNotHeld) {
+		t.Fatalf("want ErrLeaseNotHeld, got: %v", err)
+	}
+	if err := l.Release("task", "a", false); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if err := l.Acquire("task", "b", 0); err != nil {
+		t.Fatalf("acquire of a released lease failed: %v", err)
+	}
+	// the lease of b is expired
+	if err := l.Acquire("task", "a", time.Hour); err != nil {
+		t.Fatalf("acquire of an expired lease failed: %v", err)
+	}
+	if err := l.Release("task", "a", true); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if err := l.Acquire("task", "b", time.Hour); !errors.Is(err, ErrLeaseDone) {
+		t.Fatalf("want ErrLeaseDone, got: %v", err)
+	}
+
+	// done marks expire, those that are not acquired again are
+	// discarded as well.
+	if err := l.Acquire("other", "a", time.Hour); err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+	if err := l.Release("other", "a", true); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	c.advance(doneTTL)
+	if err := l.Acquire("task", "b", time.Hour); err != nil {
+		t.Fatalf("acquire of an expired done mark failed: %v", err)
+	}
+	if err := l.Release("task", "b", false); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+}
+
+func TestMemoryLeaser(t *testing.T) {
+	c := &manualClock{now: time.Now()}
+	l := NewMemoryLeaser(c, doneTTL)
+	testLeaser(t, l, c)
+	if len(l.leases) != 0 {
+		t.Fatalf("expired leases are kept: %v", l.leases)
+	}
+}
+
+func TestSchedLeaser(t *testing.T) {
+	const nTasks = 10
+	l := NewMemoryLeaser(nil, time.Minute)
+
+	var (
+		mu       sync.Mutex
+		executed = map[string]int{}
+	)
+	newTask := func(id string, e time.Time) *FuncTask[string] {
+		return NewFuncTask(id, e, func() (string, bool, error) {
+			mu.Lock()
+			executed[id]++
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			return id, false, nil
+		})
+	}
+
+	// tasks leased by a dead instance are recovered after the ttl
+	l.Acquire("task-0", "dead", 20*time.Millisecond)
+
+	now := time.Now()
+	var futures []*Future[string]
+	for _, owner := range []string{"a", "b"} {
+		s := NewSched[string, *FuncTask[string]](WithLeaser(l, owner, 20*time.Millisecond))
+		defer s.Stop()
+		for i := 0; i < nTasks; i++ {
+			futures = append(futures, s.Submit(newTask(fmt.Sprintf("task-%d", i), now)))
+		}
+	}
+
+	done := 0
+	for _, f := range futures {
+		v, err := f.GetTimeout(time.Second)
+		switch {
+		case err == nil && v != "":
+			done++
+		case errors.Is(err, ErrLeaseDone):
+		default:
+			t.Fatalf("unexpected result: %v, %v", v, err)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if done != nTasks || len(executed) != nTasks {
+		t.Fatalf("want %d executed tasks, got %d, %v", nTasks, done, executed)
+	}
+	for id, n := range executed {
+		if n != 1 {
+			t.Fatalf("task %s is executed %d times", id, n)
+		}
+	}
+}
+
+// countingLimiter is a Limiter without limit that counts the permits.
+type countingLimiter struct {
+	mu    sync.Mutex
+	taken int
+}
+
+func (l *countingLimiter) Delay(time.Time) time.Duration { return 0 }
+
+func (l *countingLimiter) Take(time.Time) {
+	l.mu.Lock()
+	l.taken++
+	l.mu.Unlock()
+}
+
+func TestSchedLeaserLimit(t *testing.T) {
+	l := NewMemoryLeaser(nil, time.Minute)
+	lim := &countingLimiter{}
+	s := NewSched[string, *FuncTask[string]](WithLeaser(l, "a", time.Minute), WithRateLimit(lim))
+	defer s.Stop()
+
+	// the task is done by another owner.
+	l.Acquire("done", "b", time.Minute)
+	l.Release("done", "b", true)
+	f := s.Submit(NewFuncTask("done", time.Now(), func() (string, bool, error) {
+		t.Errorf("task done by another owner was executed")
+		return "", false, nil
+	}))
+	if _, err := f.GetTimeout(time.Second); !errors.Is(err, ErrLeaseDone) {
+		t.Fatalf("want ErrLeaseDone, got: %v", err)
+	}
+	f = s.Submit(NewFuncTask("run", time.Now(), func() (string, bool, error) {
+		return "run", false, nil
+	}))
+	if _, err := f.GetTimeout(time.Second); err != nil {
+		t.Fatalf("task failed: %v", err)
+	}
+
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	if lim.taken != 1 {
+		t.Fatalf("want 1 permit taken, got %d", lim.taken)
+	}
+}
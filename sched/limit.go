@@ -0,0 +1,131 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sched
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter limits the rate of task executions. The time is given by
+// the scheduler, so that a Limiter works with any Clock.
+type Limiter interface {
+	// Delay returns how long an execution must wait at now for a
+	// permit, zero means a permit is available.
+	Delay(now time.Time) time.Duration
+	// Take consumes a permit at now.
+	Take(now time.Time)
+}
+
+// GroupedTask is an optional interface of a Task. The executions of the
+// tasks of the same group are limited by the group limiter of the
+// scheduler.
+type GroupedTask interface {
+	GetGroup() string
+}
+
+// WithRateLimit limits the executions of all tasks of a scheduler by l.
+// A due task over the limit is not dropped but rescheduled at the time
+// of the next permit.
+func WithRateLimit(l Limiter) Option {
+	return func(o *options) {
+		o.limiter = l
+	}
+}
+
+// WithGroupRateLimit limits the executions of the tasks of each group
+// by a separate limiter, which is created by newLimiter when a task of
+// the group first executes. Tasks without a group or of the empty group
+// are not limited by it. It applies together with WithRateLimit.
+func WithGroupRateLimit(newLimiter func(group string) Limiter) Option {
+	return func(o *options) {
+		o.groupLimiter = newLimiter
+	}
+}
+
+// TokenBucket is a Limiter that permits rate executions per second on
+// average and bursts of up to burst executions.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket returns a full token bucket.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return &TokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// Delay implements Limiter.
+func (b *TokenBucket) Delay(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(now)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration(math.Ceil((1 - b.tokens) / b.rate * float64(time.Second)))
+}
+
+// Take implements Limiter.
+func (b *TokenBucket) Take(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(now)
+	b.tokens--
+}
+
+// advance refills the tokens for the time elapsed since the last call.
+func (b *TokenBucket) advance(now time.Time) {
+	if !b.last.IsZero() && now.After(b.last) {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	if now.After(b.last) {
+		b.last = now
+	}
+}
+
+// limit takes the permits of a due task at now. If the task is over the
+// limit, it returns the delay until the next permit.
+func (s *sched[R, T]) limit(t *task[R], now time.Time) time.Duration {
+	if s.opts.limiter == nil && s.opts.groupLimiter == nil {
+		return 0
+	}
+
+	// the permits of all limiters are taken together or not at all.
+	s.limitMu.Lock()
+	defer s.limitMu.Unlock()
+
+	limiters := make([]Limiter, 0, 2)
+	if s.opts.limiter != nil {
+		limiters = append(limiters, s.opts.limiter)
+	}
+	if gt, ok := t.value.(GroupedTask); ok && s.opts.groupLimiter != nil && gt.GetGroup() != "" {
+		group := gt.GetGroup()
+		l, ok := s.limiters[group]
+		if !ok {
+			l = s.opts.groupLimiter(group)
+			s.limiters[group] = l
+		}
+		limiters = append(limiters, l)
+	}
+
+	var delay time.Duration
+	for _, l := range limiters {
+		if d := l.Delay(now); d > delay {
+			delay = d
+		}
+	}
+	if delay > 0 {
+		return delay
+	}
+	for _, l := range limiters {
+		l.Take(now)
+	}
+	return 0
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sched_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.design/x/go2generics/sched"
+	"golang.design/x/go2generics/sched/schedtest"
+)
+
+// GroupTask is a FuncTask of a group
+type GroupTask struct {
+	*sched.FuncTask[time.Time]
+	group string
+}
+
+// GetGroup returns the group of the task
+func (t GroupTask) GetGroup() string { return t.group }
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := sched.NewTokenBucket(10, 2)
+	for i := 0; i < 2; i++ {
+		if d := b.Delay(now); d != 0 {
+			t.Fatalf("burst is not permitted, delay: %v", d)
+		}
+		b.Take(now)
+	}
+	if d := b.Delay(now); d != 100*time.Millisecond {
+		t.Fatalf("want delay 100ms, got %v", d)
+	}
+	if d := b.Delay(now.Add(50 * time.Millisecond)); d != 50*time.Millisecond {
+		t.Fatalf("want delay 50ms, got %v", d)
+	}
+	if d := b.Delay(now.Add(100 * time.Millisecond)); d != 0 {
+		t.Fatalf("token is not refilled, delay: %v", d)
+	}
+	if d := b.Delay(now.Add(time.Hour)); d != 0 {
+		t.Fatalf("token is not refilled, delay: %v", d)
+	}
+	b.Take(now.Add(time.Hour))
+	b.Take(now.Add(time.Hour))
+	if d := b.Delay(now.Add(time.Hour)); d == 0 {
+		t.Fatalf("tokens exceed the burst")
+	}
+}
+
+func TestSchedRateLimit(t *testing.T) {
+	const nTasks, rate = 10, 200
+	c := schedtest.NewClock(epoch)
+	sched0 := sched.NewSched[time.Time, GroupTask](
+		sched.WithClock(c),
+		sched.WithRateLimit(sched.NewTokenBucket(rate, 1)),
+		sched.WithGroupRateLimit(func(group string) sched.Limiter {
+			return sched.NewTokenBucket(rate/4, 1)
+		}),
+	)
+	defer sched0.Stop()
+
+	var (
+		mu     sync.Mutex
+		groups = map[string][]time.Time{}
+	)
+	newTask := func(id, group string, e time.Time) GroupTask {
+		return GroupTask{FuncTask: sched.NewFuncTask(id, e, func() (time.Time, bool, error) {
+			now := c.Now()
+			mu.Lock()
+			groups[group] = append(groups[group], now)
+			mu.Unlock()
+			return now, false, nil
+		}), group: group}
+	}
+
+	// the sentinel keeps a timer armed while the clock is advanced.
+	sched0.Submit(newTask("sentinel", "", epoch.Add(time.Hour)))
+	var futures []*sched.Future[time.Time]
+	for i := 0; i < nTasks; i++ {
+		futures = append(futures, sched0.Submit(newTask(fmt.Sprintf("task-%d", i), "", epoch)))
+	}
+	for i := 0; i < 2; i++ {
+		futures = append(futures, sched0.Submit(newTask(fmt.Sprintf("slow-%d", i), "slow", epoch)))
+	}
+	done := make(chan struct{})
+	go func() {
+		for _, f := range futures {
+			f.Get()
+		}
+		close(done)
+	}()
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		default:
+			c.BlockUntil(1)
+			c.Advance(time.Millisecond)
+		}
+	}
+	sched0.Cancel("sentinel")
+
+	var last time.Time
+	for _, f := range futures {
+		if f.Err != nil {
+			t.Fatalf("task failed: %v", f.Err)
+		}
+		if f.Get().After(last) {
+			last = f.Get()
+		}
+	}
+	// all tasks share the global limit, the first one uses the burst.
+	if d := last.Sub(epoch); d < (nTasks+2-1)*time.Second/rate {
+		t.Fatalf("tasks are executed too fast: %v", d)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// the second task of the group waits for the group limit.
+	slow := groups["slow"]
+	if len(slow) != 2 || slow[0].Sub(epoch) < 4*time.Second/rate && slow[1].Sub(epoch) < 4*time.Second/rate {
+		t.Fatalf("group limit is not respected: %v", slow)
+	}
+}
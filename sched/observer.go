@@ -0,0 +1,243 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sched
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Event describes what happens to a task.
+type Event struct {
+	// ID is the ID of the task.
+	ID string
+	// Execution is the time that the task is expected to run, i.e.
+	// GetExecution of a one-shot task, or the time of the occurrence
+	// of a recurring task.
+	Execution time.Time
+	// Attempt is the number of failed executions before this event.
+	Attempt int
+	// Next is the time of the retry of an OnRetry event.
+	Next time.Time
+	// Lateness is the delay of the start of the execution to its
+	// expected time.
+	Lateness time.Duration
+	// Latency is the duration of the execution.
+	Latency time.Duration
+	// Executed reports whether the task executed, it is false for an
+	// OnComplete event of a task that completes without executing,
+	// e.g. it is canceled or one of its dependencies fails.
+	Executed bool
+	// Err is the failure of the execution, a *PanicError if the task
+	// panics.
+	Err error
+}
+
+// Observer observes the lifecycle of tasks in a scheduler. The methods
+// are called synchronously by the scheduler and must not block.
+type Observer interface {
+	// OnSubmit is called when a task is scheduled.
+	OnSubmit(e Event)
+	// OnStart is called before a task executes.
+	OnStart(e Event)
+	// OnRetry is called when a failed task is rescheduled.
+	OnRetry(e Event)
+	// OnPanic is called when a task panics.
+	OnPanic(e Event)
+	// OnComplete is called when a task succeeds, fails without more
+	// retries or is canceled, and for each occurrence of a recurring
+	// task.
+	OnComplete(e Event)
+}
+
+// WithObserver adds an observer to a scheduler.
+func WithObserver(o Observer) Option {
+	return func(opts *options) {
+		opts.observers = append(opts.observers, o)
+	}
+}
+
+// PanicError is the error of a task that panics while executing.
+type PanicError struct {
+	// ID is the ID of the task.
+	ID string
+	// Value is the value passed to panic.
+	Value any
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("sched: task %s panic while executing, reason: %v", e.ID, e.Value)
+}
+
+// observers broadcasts events to a list of observers.
+type observers []Observer
+
+func (os observers) OnSubmit(e Event) {
+	for _, o := range os {
+		o.OnSubmit(e)
+	}
+}
+
+func (os observers) OnStart(e Event) {
+	for _, o := range os {
+		o.OnStart(e)
+	}
+}
+
+func (os observers) OnRetry(e Event) {
+	for _, o := range os {
+		o.OnRetry(e)
+	}
+}
+
+func (os observers) OnPanic(e Event) {
+	for _, o := range os {
+		o.OnPanic(e)
+	}
+}
+
+func (os observers) OnComplete(e Event) {
+	for _, o := range os {
+		o.OnComplete(e)
+	}
+}
+
+// Metrics is an Observer that counts events and records histograms of
+// latency and lateness. It implements expvar.Var, so it can be
+// published by expvar.Publish.
+type Metrics struct {
+	mu        sync.Mutex
+	submitted int64
+	started   int64
+	retried   int64
+	panicked  int64
+	succeeded int64
+	failed    int64
+	canceled  int64
+	latency   histogram
+	lateness  histogram
+}
+
+// NewMetrics returns an empty Metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{}
+}
+
+// OnSubmit implements Observer.
+func (m *Metrics) OnSubmit(e Event) {
+	m.mu.Lock()
+	m.submitted++
+	m.mu.Unlock()
+}
+
+// OnStart implements Observer.
+func (m *Metrics) OnStart(e Event) {
+	m.mu.Lock()
+	m.started++
+	m.lateness.observe(e.Lateness)
+	m.mu.Unlock()
+}
+
+// OnRetry implements Observer.
+func (m *Metrics) OnRetry(e Event) {
+	m.mu.Lock()
+	m.retried++
+	m.mu.Unlock()
+}
+
+// OnPanic implements Observer.
+func (m *Metrics) OnPanic(e Event) {
+	m.mu.Lock()
+	m.panicked++
+	m.mu.Unlock()
+}
+
+// OnComplete implements Observer.
+func (m *Metrics) OnComplete(e Event) {
+	m.mu.Lock()
+	switch {
+	case errors.Is(e.Err, ErrCanceled):
+		m.canceled++
+	case e.Err != nil:
+		m.failed++
+	default:
+		m.succeeded++
+	}
+	if e.Executed {
+		m.latency.observe(e.Latency)
+	}
+	m.mu.Unlock()
+}
+
+// String returns the metrics in JSON, it implements expvar.Var.
+func (m *Metrics) String() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, _ := json.Marshal(map[string]any{
+		"submitted": m.submitted,
+		"started":   m.started,
+		"retried":   m.retried,
+		"panicked":  m.panicked,
+		"succeeded": m.succeeded,
+		"failed":    m.failed,
+		"canceled":  m.canceled,
+		"latency":   m.latency.snapshot(),
+		"lateness":  m.lateness.snapshot(),
+	})
+	return string(b)
+}
+
+// histogramBounds are the upper bounds of the histogram buckets.
+var histogramBounds = [...]time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	time.Minute,
+}
+
+// histogram counts durations in buckets of histogramBounds, the last
+// bucket counts the durations greater than all the bounds.
+type histogram struct {
+	count   int64
+	sum     time.Duration
+	buckets [len(histogramBounds) + 1]int64
+}
+
+func (h *histogram) observe(d time.Duration) {
+	h.count++
+	h.sum += d
+	i := 0
+	for i < len(histogramBounds) && d > histogramBounds[i] {
+		i++
+	}
+	h.buckets[i]++
+}
+
+func (h *histogram) snapshot() map[string]any {
+	buckets := make(map[string]int64, len(h.buckets))
+	for i, n := range h.buckets {
+		le := "+Inf"
+		if i < len(histogramBounds) {
+			le = histogramBounds[i].String()
+		}
+		buckets[le] = n
+	}
+	return map[string]any{
+		"count":   h.count,
+		"sum_ms":  float64(h.sum) / float64(time.Millisecond),
+		"buckets": buckets,
+	}
+}
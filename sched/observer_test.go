@@ -0,0 +1,108 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sched
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder is an Observer that records the events
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+	last   map[string]Event
+}
+
+func (r *recorder) record(kind string, e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.last == nil {
+		r.last = map[string]Event{}
+	}
+	r.events = append(r.events, kind+":"+e.ID)
+	r.last[kind] = e
+}
+
+func (r *recorder) OnSubmit(e Event)   { r.record("submit", e) }
+func (r *recorder) OnStart(e Event)    { r.record("start", e) }
+func (r *recorder) OnRetry(e Event)    { r.record("retry", e) }
+func (r *recorder) OnPanic(e Event)    { r.record("panic", e) }
+func (r *recorder) OnComplete(e Event) { r.record("complete", e) }
+
+func TestSchedObserver(t *testing.T) {
+	rec := &recorder{}
+	m := NewMetrics()
+	sched0 := NewSched[int, *FuncTask[int]](
+		WithObserver(rec),
+		WithObserver(m),
+		WithRetryPolicy(MaxAttempts(2, Fixed(time.Millisecond))),
+	)
+	defer sched0.Stop()
+
+	errFail := errors.New("failure")
+	f := sched0.Submit(NewFuncTask("fail", time.Now(), func() (int, bool, error) {
+		return 0, false, errFail
+	}))
+	f.Get()
+	f = sched0.Submit(NewFuncTask("panic", time.Now(), func() (int, bool, error) {
+		panic("boom")
+	}))
+	f.Get()
+
+	var perr *PanicError
+	if !errors.As(f.Err, &perr) || perr.Value != "boom" || len(perr.Stack) == 0 {
+		t.Fatalf("want a PanicError with stack, got: %#v", f.Err)
+	}
+
+	rec.mu.Lock()
+	want := []string{
+		"submit:fail", "start:fail", "retry:fail", "start:fail", "complete:fail",
+		"submit:panic", "start:panic", "panic:panic", "complete:panic",
+	}
+	if len(rec.events) != len(want) {
+		t.Fatalf("unexpected events: %v", rec.events)
+	}
+	for i := range want {
+		if rec.events[i] != want[i] {
+			t.Fatalf("unexpected events: %v", rec.events)
+		}
+	}
+	if e := rec.last["retry"]; e.Attempt != 1 || !errors.Is(e.Err, errFail) || e.Next.IsZero() {
+		t.Fatalf("unexpected retry event: %+v", e)
+	}
+	if e := rec.last["complete"]; e.Lateness < 0 || e.Latency < 0 || !e.Executed || !errors.As(e.Err, &perr) {
+		t.Fatalf("unexpected complete event: %+v", e)
+	}
+	rec.mu.Unlock()
+
+	// a canceled task completes without executing.
+	sched0.Submit(NewFuncTask("cancel", time.Now().Add(time.Hour), func() (int, bool, error) {
+		return 0, false, nil
+	}))
+	sched0.Cancel("cancel")
+	rec.mu.Lock()
+	if e := rec.last["complete"]; e.ID != "cancel" || e.Executed || e.Latency != 0 || !errors.Is(e.Err, ErrCanceled) {
+		t.Fatalf("unexpected complete event: %+v", e)
+	}
+	rec.mu.Unlock()
+
+	var v expvar.Var = m
+	var got struct {
+		Submitted, Started, Retried, Panicked, Succeeded, Failed, Canceled int
+		Latency                                                            struct{ Count int }
+	}
+	if err := json.Unmarshal([]byte(v.String()), &got); err != nil {
+		t.Fatalf("metrics is not valid JSON: %v", err)
+	}
+	if got.Submitted != 3 || got.Started != 3 || got.Retried != 1 || got.Panicked != 1 ||
+		got.Succeeded != 0 || got.Failed != 2 || got.Canceled != 1 || got.Latency.Count != 2 {
+		t.Fatalf("unexpected metrics: %s", m)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sched
+
+import "time"
+
+// Option configures a scheduler created by NewSched.
+type Option func(o *options)
+
+// options are the configurations of a scheduler.
+type options struct {
+	// workers is the maximum number of concurrent task executions,
+	// zero means unlimited.
+	workers int
+	// retry is the default retry policy of tasks, nil means retry at
+	// Task.GetRetryTime forever.
+	retry RetryPolicy
+	// errorHandler handles the errors that happen in the background
+	errorHandler func(err error)
+	// observers observe the events of tasks
+	observers observers
+	// clock is the source of time
+	clock Clock
+	// leaser grants the leases of tasks to owner for ttl, it is nil if
+	// tasks are not shared with other schedulers.
+	leaser Leaser
+	owner  string
+	ttl    time.Duration
+	// limiter limits all executions, and groupLimiter creates a
+	// limiter of each group of tasks.
+	limiter      Limiter
+	groupLimiter func(group string) Limiter
+}
+
+// WithWorkers limits the number of concurrently executing tasks to n.
+// Due tasks that exceed the limit wait in a ready queue in the order
+// of their arrival. A non-positive n means unlimited.
+func WithWorkers(n int) Option {
+	return func(o *options) {
+		o.workers = n
+	}
+}
+
+// WithErrorHandler sets the handler of the errors that happen in the
+// background of a scheduler and cannot be returned to a caller, e.g.
+// a *StoreError. By default the errors are logged by package log.
+func WithErrorHandler(h func(err error)) Option {
+	return func(o *options) {
+		o.errorHandler = h
+	}
+}
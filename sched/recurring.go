@@ -0,0 +1,168 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sched
+
+import (
+	"sync"
+	"time"
+)
+
+// Result is the outcome of one occurrence of a recurring task.
+type Result[R any] struct {
+	// Value is the result of the execution.
+	Value R
+	// Err is the failure of the execution, after the retry policy of
+	// the task is exhausted.
+	Err error
+	// Time is the scheduled time of the occurrence.
+	Time time.Time
+}
+
+// Stream delivers the results of a recurring task.
+type Stream[R any] struct {
+	c chan Result[R]
+	// done is closed when the task is canceled while it executes.
+	done chan struct{}
+	once sync.Once
+}
+
+func newStream[R any]() *Stream[R] {
+	return &Stream[R]{c: make(chan Result[R], 1), done: make(chan struct{})}
+}
+
+// C returns the channel of the results of each occurrence. The channel
+// is closed when the schedule has no more occurrence or the task is
+// canceled.
+//
+// The next occurrence is not scheduled until the result of the
+// current one is received, hence a slow receiver delays the task. A
+// receiver that stops receiving should cancel the task, otherwise the
+// task is kept until the scheduler stops.
+func (s *Stream[R]) C() <-chan Result[R] {
+	return s.c
+}
+
+// SubmitRecurring submits a task that is executed at every occurrence
+// of the given schedule, starting from the first occurrence after now.
+// The task is re-enqueued after each execution, and the future of the
+// task is resolved only when the stream ends. A pending task of the
+// same ID is canceled.
+func (sched0 *sched[R, T]) SubmitRecurring(t T, every Schedule) *Stream[R] {
+	sched0.Cancel(t.GetID())
+
+	item := newTaskItem[R](t, every.Next(sched0.opts.clock.Now()))
+	item.every = every
+	item.occurrence = item.priority
+	item.stream = newStream[R]()
+	if item.priority.IsZero() {
+		item.finish()
+		return item.stream
+	}
+
+	sched0.opts.observers.OnSubmit(Event{ID: item.value.GetID(), Execution: item.priority})
+	sched0.track(item)
+	sched0.pause()
+	sched0.tasks.push(item)
+	sched0.resume()
+	return item.stream
+}
+
+// deliver sends the result of an occurrence of a recurring task to its
+// stream, then schedules the next occurrence. If the receiver is not
+// ready, the result is sent by another goroutine, so that a slow
+// receiver does not hold the worker.
+func (s *sched[R, T]) deliver(t *task[R], result R, err error) {
+	if t.stream.canceled() {
+		s.end(t)
+		return
+	}
+	r := Result[R]{Value: result, Err: err, Time: t.occurrence}
+	select {
+	case t.stream.c <- r:
+		s.next(t)
+		return
+	default:
+	}
+
+	// a delivering task stays active until its result is received
+	s.active.Add(1)
+	go func() {
+		defer s.release()
+		select {
+		case t.stream.c <- r:
+			s.next(t)
+		case <-t.stream.done:
+			s.end(t)
+		}
+	}()
+}
+
+// next schedules the next occurrence of a recurring task, or ends its
+// stream if there is no more occurrence.
+func (s *sched[R, T]) next(t *task[R]) {
+	now := s.opts.clock.Now()
+	next := t.every.Next(t.occurrence)
+	// skip the occurrences that are already missed
+	if !next.IsZero() && next.Before(now) {
+		next = t.every.Next(now)
+	}
+	if next.IsZero() {
+		s.end(t)
+		return
+	}
+	t.occurrence = next
+	t.attempts = 0
+	s.reschedule(t, next)
+}
+
+// end forgets a recurring task and ends its stream.
+func (s *sched[R, T]) end(t *task[R]) {
+	if t.stream.canceled() {
+		s.untrack(t, ErrCanceled)
+	} else {
+		s.untrack(t, nil)
+	}
+	t.finish()
+}
+
+// finish ends the stream of a recurring task, its future is resolved
+// with ErrCanceled if the task is canceled.
+func (t *task[R]) finish() {
+	close(t.stream.c)
+	var zero R
+	var err error
+	if t.stream.canceled() {
+		err = ErrCanceled
+	}
+	t.future.resolve(zero, err)
+}
+
+// cancel stops the stream of a task that is executing or delivering,
+// the current occurrence is not delivered if it is not received yet.
+func (s *Stream[R]) cancel() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// canceled reports whether the stream is canceled.
+func (s *Stream[R]) canceled() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop cancels the stream of an executing or delivering recurring task
+// by its id, and reports whether there is one.
+func (s *sched[R, T]) stop(id string) bool {
+	s.mu.Lock()
+	st, ok := s.streams[id]
+	s.mu.Unlock()
+	if ok {
+		st.cancel()
+	}
+	return ok
+}
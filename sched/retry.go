@@ -0,0 +1,102 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sched
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Attempt describes a failed execution of a task.
+type Attempt struct {
+	// N is the number of failed executions so far, starting from 1.
+	N int
+	// First is the time of the first execution.
+	First time.Time
+	// Now is the time when the execution failed.
+	Now time.Time
+	// Err is the error returned by the execution, it is nil if the
+	// task only asked for a retry.
+	Err error
+}
+
+// RetryPolicy decides if and when a failed task is retried.
+type RetryPolicy interface {
+	// Next returns the delay before the next execution of the task,
+	// or false if the task should not be retried anymore.
+	Next(a Attempt) (delay time.Duration, ok bool)
+}
+
+// RetryPolicyTask is an optional interface of a Task. If a task
+// implements it, its policy overrides the policy of the scheduler.
+type RetryPolicyTask interface {
+	GetRetryPolicy() RetryPolicy
+}
+
+// WithRetryPolicy sets the default retry policy of a scheduler.
+// Without a policy, a failed task is retried at its GetRetryTime
+// without limit.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = p
+	}
+}
+
+// RetryFunc is an adapter to use an ordinary function as a RetryPolicy.
+type RetryFunc func(a Attempt) (time.Duration, bool)
+
+// Next calls f(a).
+func (f RetryFunc) Next(a Attempt) (time.Duration, bool) {
+	return f(a)
+}
+
+// Fixed returns a policy that always retries after d.
+func Fixed(d time.Duration) RetryPolicy {
+	return RetryFunc(func(Attempt) (time.Duration, bool) {
+		return d, true
+	})
+}
+
+// Exponential returns a policy that doubles the delay after each
+// attempt starting from base and capped by max. A jitter in [0, 1]
+// randomly shortens each delay by up to that fraction, so that tasks
+// failed at the same time do not retry at the same time.
+func Exponential(base, max time.Duration, jitter float64) RetryPolicy {
+	return RetryFunc(func(a Attempt) (time.Duration, bool) {
+		d := base
+		for i := 1; i < a.N && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		if jitter > 0 {
+			d -= time.Duration(jitter * rand.Float64() * float64(d))
+		}
+		return d, true
+	})
+}
+
+// MaxAttempts limits p to at most n executions of a task in total.
+func MaxAttempts(n int, p RetryPolicy) RetryPolicy {
+	return RetryFunc(func(a Attempt) (time.Duration, bool) {
+		if a.N >= n {
+			return 0, false
+		}
+		return p.Next(a)
+	})
+}
+
+// Deadline limits p so that no retry happens later than d after the
+// first execution of a task.
+func Deadline(d time.Duration, p RetryPolicy) RetryPolicy {
+	return RetryFunc(func(a Attempt) (time.Duration, bool) {
+		delay, ok := p.Next(a)
+		if !ok || a.Now.Add(delay).After(a.First.Add(d)) {
+			return 0, false
+		}
+		return delay, true
+	})
+}
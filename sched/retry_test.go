@@ -0,0 +1,106 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sched
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// PolicyTask is a FuncTask with its own retry policy
+type PolicyTask struct {
+	*FuncTask[int]
+	policy RetryPolicy
+}
+
+// GetRetryPolicy returns the retry policy of the task
+func (t PolicyTask) GetRetryPolicy() RetryPolicy { return t.policy }
+
+func TestExponential(t *testing.T) {
+	p := Exponential(time.Millisecond, 10*time.Millisecond, 0)
+	want := []time.Duration{1, 2, 4, 8, 10, 10}
+	for i, w := range want {
+		d, ok := p.Next(Attempt{N: i + 1})
+		if !ok || d != w*time.Millisecond {
+			t.Fatalf("attempt %d: got %v, want %v", i+1, d, w*time.Millisecond)
+		}
+	}
+
+	p = Exponential(time.Second, time.Minute, 0.5)
+	for i := 0; i < 100; i++ {
+		d, _ := p.Next(Attempt{N: 2})
+		if d < time.Second || d > 2*time.Second {
+			t.Fatalf("jitter out of range: %v", d)
+		}
+	}
+}
+
+func TestMaxAttemptsDeadline(t *testing.T) {
+	p := MaxAttempts(3, Fixed(time.Second))
+	if _, ok := p.Next(Attempt{N: 2}); !ok {
+		t.Fatalf("MaxAttempts stops too early")
+	}
+	if _, ok := p.Next(Attempt{N: 3}); ok {
+		t.Fatalf("MaxAttempts does not stop")
+	}
+
+	now := time.Now()
+	p = Deadline(time.Minute, Fixed(time.Second))
+	if _, ok := p.Next(Attempt{N: 1, First: now, Now: now}); !ok {
+		t.Fatalf("Deadline stops too early")
+	}
+	if _, ok := p.Next(Attempt{N: 1, First: now, Now: now.Add(time.Minute)}); ok {
+		t.Fatalf("Deadline does not stop")
+	}
+}
+
+func TestSchedRetryPolicy(t *testing.T) {
+	errFail := errors.New("permanent failure")
+	sched0 := NewSched[int, *FuncTask[int]](WithRetryPolicy(MaxAttempts(3, Fixed(time.Millisecond))))
+	defer sched0.Stop()
+
+	var n uint64
+	f := sched0.Submit(NewFuncTask("fail", time.Now(), func() (int, bool, error) {
+		atomic.AddUint64(&n, 1)
+		return 0, false, errFail
+	}))
+	if _, err := f.GetTimeout(time.Second); !errors.Is(err, errFail) {
+		t.Fatalf("want final error %v, got: %v", errFail, err)
+	}
+	if n := atomic.LoadUint64(&n); n != 3 {
+		t.Fatalf("want 3 executions, got %d", n)
+	}
+
+	f = sched0.Submit(NewFuncTask("retry", time.Now(), func() (int, bool, error) {
+		return 0, true, nil
+	}))
+	if _, err := f.GetTimeout(time.Second); !errors.Is(err, ErrRetryExhausted) {
+		t.Fatalf("want ErrRetryExhausted, got: %v", err)
+	}
+}
+
+func TestSchedTaskRetryPolicy(t *testing.T) {
+	sched0 := NewSched[int, PolicyTask](WithRetryPolicy(Fixed(time.Millisecond)))
+	defer sched0.Stop()
+
+	var n uint64
+	f := sched0.Submit(PolicyTask{
+		FuncTask: NewFuncTask("fail", time.Now(), func() (int, bool, error) {
+			if atomic.AddUint64(&n, 1) < 5 {
+				return 0, true, nil
+			}
+			return 42, false, nil
+		}),
+		policy: MaxAttempts(2, Fixed(time.Millisecond)),
+	})
+	if _, err := f.GetTimeout(time.Second); !errors.Is(err, ErrRetryExhausted) {
+		t.Fatalf("task policy is not applied, got: %v", err)
+	}
+	if n := atomic.LoadUint64(&n); n != 2 {
+		t.Fatalf("want 2 executions, got %d", n)
+	}
+}
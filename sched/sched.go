@@ -1,34 +1,96 @@
 package sched
 
 import (
-	"fmt"
+	"errors"
 	"sync"
 	"time"
 
 	"container/heap"
 	"context"
+	"log"
 	"runtime"
-	"sync/atomic"
-	"unsafe"
+	"runtime/debug"
+	"sort"
+
+	"golang.design/x/go2generics/sync/atomic"
 )
 
+// ErrTimeout is returned by Future.GetTimeout if the future is not
+// resolved within the given duration.
+var ErrTimeout = errors.New("sched: future timeout")
+
+// ErrCanceled is the error of a future whose task was canceled before
+// its execution.
+var ErrCanceled = errors.New("sched: task canceled")
+
+// ErrRetryExhausted is the error of a future whose task asked for a
+// retry without an error but its retry policy is exhausted.
+var ErrRetryExhausted = errors.New("sched: retry exhausted")
+
+// Future is the result of a scheduled task. A Future is resolved
+// exactly once, either with the result of the task or with an error.
 type Future[R any] struct {
+	// Err reports the failure of the task. It is only valid after
+	// the channel returned by Done is closed.
 	Err   error
-	value atomic.Value
+	value R
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newFuture[R any]() *Future[R] {
+	return &Future[R]{done: make(chan struct{})}
 }
 
-// Get implements Future interface
+// Get blocks until the future is resolved and returns its value.
+// If the task failed, the zero value of R is returned and the reason
+// is stored in Err.
 func (f *Future[R]) Get() R {
-	var v any
-	// spin until value is stored in future.value
-	for ; v == nil && f.Err == nil; v = f.value.Load() {
-		runtime.Gosched()
+	<-f.done
+	return f.value
+}
+
+// GetContext blocks until the future is resolved or ctx is done.
+// It returns the task error if the task failed, or ctx.Err() if the
+// context ends first.
+func (f *Future[R]) GetContext(ctx context.Context) (R, error) {
+	select {
+	case <-f.done:
+		return f.value, f.Err
+	case <-ctx.Done():
+		var zero R
+		return zero, ctx.Err()
+	}
+}
+
+// GetTimeout is like GetContext but gives up after d and returns
+// ErrTimeout.
+func (f *Future[R]) GetTimeout(d time.Duration) (R, error) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-f.done:
+		return f.value, f.Err
+	case <-t.C:
+		var zero R
+		return zero, ErrTimeout
 	}
-	return v.(R)
 }
 
-func (f *Future[R]) put(v R) {
-	f.value.Store(v)
+// Done returns a channel that is closed when the future is resolved.
+// It can be used to select on many futures at once.
+func (f *Future[R]) Done() <-chan struct{} {
+	return f.done
+}
+
+// resolve resolves the future with the given value and error, only
+// the first call takes effect.
+func (f *Future[R]) resolve(v R, err error) {
+	f.once.Do(func() {
+		f.value = v
+		f.Err = err
+		close(f.done)
+	})
 }
 
 // Task interface for sched
@@ -44,45 +106,162 @@ type Task[R any] interface {
 	Execute() (result R, retry bool, fail error)
 }
 
+// Scheduler schedules tasks of type T that produce results of type R.
+type Scheduler[R any, T Task[R]] interface {
+	// Submit schedules a task at its execution time. A pending task of
+	// the same ID is updated and its future is returned.
+	Submit(t T) *Future[R]
+	// Trigger schedules a task to execute immediately.
+	Trigger(t T) *Future[R]
+	// SubmitRecurring schedules a task at every occurrence of a schedule.
+	SubmitRecurring(t T, every Schedule) *Stream[R]
+	// Cancel cancels a pending task by its ID.
+	Cancel(id string) bool
+	// Restore schedules the tasks saved in the store of the scheduler.
+	Restore() (map[string]*Future[R], error)
+
+	// Len returns the number of tasks waiting for their execution time.
+	Len() int
+	// Pending returns a snapshot of the tasks waiting for their
+	// execution time, ordered by the time.
+	Pending() []PendingTask
+	// Lookup returns the future of an unfinished task by its ID.
+	Lookup(id string) (*Future[R], bool)
+	// Stats returns the current load of the scheduler.
+	Stats() Stats
+
+	// Pause stops the scheduler from starting tasks.
+	Pause()
+	// Resume resumes a paused scheduler.
+	Resume()
+	// Wait waits until all tasks are taken from the queue.
+	Wait()
+	// Drain blocks until all tasks are finished or ctx is done.
+	Drain(ctx context.Context) error
+	// Stop stops the scheduler gracefully, it cancels the recurring
+	// tasks and waits for the started tasks.
+	Stop()
+}
+
+// PendingTask describes a task waiting for its execution time.
+type PendingTask struct {
+	ID        string
+	Execution time.Time
+}
+
 // sched is the actual scheduler for task scheduling
 //
 // sched implements greedy scheduling, with a timer and a task queue,
 // the task queue is a priority queue that orders tasks by executing
-// time. The timer is the only time.Timer lives in runtime, it serves
+// time. The timer is the only Timer lives in runtime, it serves
 // the head task in the task queue.
 type sched[R any, T Task[R]] struct {
 	// running counts the tasks already starts that cannot be stopped.
-	running uint64 // atomic
+	running atomic.Int[uint64]
+	// active counts the tasks that are taken from the task queue but
+	// not yet finished, including parked tasks.
+	active atomic.Int[int64]
 	// pausing is a sign that indicates if sched should stop running.
-	pausing uint64 // atomic
+	pausing atomic.Int[uint64]
 	// timer is the only timer during the runtime
-	timer unsafe.Pointer // *time.Timer
+	timer atomic.Pointer[Timer]
 	// cancel cancels a timer if a timer need to reset
-	cancel atomic.Value // context.CancelFunc
+	cancel atomic.Value[context.CancelFunc]
 	// tasks is a TaskQueue that stores all unscheduled tasks in memory
 	tasks *taskQueue[R]
+	// opts are the configurations of the scheduler
+	opts options
+	// store persists pending tasks, it is nil if not configured
+	store Store[T]
+	// limiters are the limiters of groups of tasks
+	limiters map[string]Limiter
+	limitMu  sync.Mutex
+
+	// mu protects the fields below
+	mu sync.Mutex
+	// ready is a FIFO queue of due tasks waiting for a worker, it is
+	// used only if the number of workers is limited.
+	ready []*task[R]
+	// workers counts the workers that are serving the ready queue
+	workers int
+	// futures are the futures of unfinished tasks by their IDs
+	futures map[string]*Future[R]
+	// parked are the due tasks waiting for their dependencies
+	parked map[string]*task[R]
+	// streams are the streams of unfinished recurring tasks by their IDs
+	streams map[string]*Stream[R]
+	// failures are the errors of failed tasks by their IDs, a failure
+	// is forgotten when a task of the same ID is scheduled again, or
+	// when no unfinished task depends on it.
+	failures map[string]error
+	// dependents counts the unfinished tasks that depend on an ID
+	dependents map[string]int
+	// changed is closed and renewed when a task finishes
+	changed chan struct{}
+}
+
+// Stats is a snapshot of the scheduler load.
+type Stats struct {
+	// Pending is the number of tasks waiting for their execution time.
+	Pending int
+	// Queued is the number of due tasks waiting for a free worker.
+	Queued int
+	// Running is the number of tasks that are executing.
+	Running int
 }
 
 // NewSched returns a scheduler that schedules type T tasks.
-func NewSched[R any, T Task[R]]() *sched[R, T] {
-	return &sched[R, T]{
-		timer: unsafe.Pointer(time.NewTimer(0)),
-		tasks: newTaskQueue[R](),
+func NewSched[R any, T Task[R]](opts ...Option) Scheduler[R, T] {
+	return newSched[R, T](opts...)
+}
+
+func newSched[R any, T Task[R]](opts ...Option) *sched[R, T] {
+	s := &sched[R, T]{
+		tasks:      newTaskQueue[R](),
+		opts:       options{clock: systemClock{}},
+		futures:    map[string]*Future[R]{},
+		parked:     map[string]*task[R]{},
+		streams:    map[string]*Stream[R]{},
+		failures:   map[string]error{},
+		dependents: map[string]int{},
+		changed:    make(chan struct{}),
+		limiters:   map[string]Limiter{},
+	}
+	for _, opt := range opts {
+		opt(&s.opts)
 	}
+	timer := s.opts.clock.NewTimer(0)
+	s.timer.Store(&timer)
+	if s.opts.errorHandler == nil {
+		s.opts.errorHandler = func(err error) { log.Print(err) }
+	}
+	return s
 }
 
-// Stop stops runtime scheduler gracefully.
+// Stop stops runtime scheduler gracefully. The recurring tasks are
+// canceled, and the started tasks are waited.
 // Note that the call should only be called then application terminates
 func (sched0 *sched[R, T]) Stop() {
 	sched0.Pause()
 
+	// recurring tasks never end by themselves
+	sched0.mu.Lock()
+	ids := make([]string, 0, len(sched0.streams))
+	for id := range sched0.streams {
+		ids = append(ids, id)
+	}
+	sched0.mu.Unlock()
+	for _, id := range ids {
+		sched0.Cancel(id)
+	}
+
 	// wait until all started tasks
-	for atomic.LoadUint64(&sched0.running) > 0 {
+	for sched0.running.Load() > 0 || sched0.queued() > 0 {
 		runtime.Gosched()
 	}
 
 	// reset pausing indicator
-	atomic.AddUint64(&sched0.pausing, ^uint64(0))
+	sched0.pausing.Sub(1)
 }
 
 // Wait waits all tasks to be scheduled.
@@ -93,6 +272,58 @@ func (sched0 *sched[R, T]) Wait() {
 	}
 }
 
+// Drain blocks until there is no pending, running or parked task, or
+// until ctx is done, in which case ctx.Err() is returned. Unlike Wait,
+// it sleeps until a task finishes instead of spinning.
+func (sched0 *sched[R, T]) Drain(ctx context.Context) error {
+	for {
+		sched0.mu.Lock()
+		changed := sched0.changed
+		sched0.mu.Unlock()
+
+		// tasks become active before they leave the queue, and go back
+		// to the queue before they become inactive, hence the order of
+		// the checks.
+		if sched0.tasks.length() == 0 && sched0.active.Load() == 0 {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Len returns the number of tasks waiting for their execution time.
+func (sched0 *sched[R, T]) Len() int {
+	return sched0.tasks.length()
+}
+
+// Pending returns a snapshot of the tasks waiting for their execution
+// time, ordered by the time.
+func (sched0 *sched[R, T]) Pending() []PendingTask {
+	return sched0.tasks.snapshot()
+}
+
+// Lookup returns the future of a task by its ID. A task is known by
+// the scheduler from its submission until it finishes.
+func (sched0 *sched[R, T]) Lookup(id string) (*Future[R], bool) {
+	sched0.mu.Lock()
+	defer sched0.mu.Unlock()
+	f, ok := sched0.futures[id]
+	return f, ok
+}
+
+// Stats returns the current load of the scheduler.
+func (sched0 *sched[R, T]) Stats() Stats {
+	return Stats{
+		Pending: sched0.tasks.length(),
+		Queued:  sched0.queued(),
+		Running: int(sched0.running.Load()),
+	}
+}
+
 // Submit given tasks
 func (sched0 *sched[R, T]) Submit(t T) *Future[R] {
 	return sched0.schedule(t, t.GetExecution())
@@ -100,23 +331,104 @@ func (sched0 *sched[R, T]) Submit(t T) *Future[R] {
 
 // Trigger given tasks immediately
 func (sched0 *sched[R, T]) Trigger(t T) *Future[R] {
-	return sched0.schedule(t, time.Now())
+	return sched0.schedule(t, sched0.opts.clock.Now())
+}
+
+// Cancel removes a pending task by its ID and resolves its future with
+// ErrCanceled. Due tasks that wait for a worker or for their
+// dependencies are pending too. It reports whether the task was found;
+// a task that already started cannot be canceled, except a recurring
+// task, whose stream ends after the current occurrence.
+func (sched0 *sched[R, T]) Cancel(id string) bool {
+	t, head := sched0.tasks.remove(id)
+	if t == nil {
+		t = sched0.unpark(id)
+	}
+	queued := false
+	if t == nil {
+		if t = sched0.dequeue(id); t == nil {
+			return sched0.stop(id)
+		}
+		queued = true
+	}
+	// the timer serves the head task, re-arm it for the new head.
+	if head {
+		sched0.pause()
+		sched0.resume()
+	}
+	if t.stream != nil {
+		close(t.stream.c)
+	}
+	sched0.forget(t)
+	sched0.untrack(t, ErrCanceled)
+	e := t.event()
+	e.Err = ErrCanceled
+	sched0.opts.observers.OnComplete(e)
+
+	var zero R
+	t.future.resolve(zero, ErrCanceled)
+	// a queued task became active when it left the task queue.
+	if queued {
+		sched0.release()
+		return true
+	}
+	sched0.notify()
+	return true
+}
+
+// Restore loads the pending tasks from the store of the scheduler and
+// schedules them at their original execution times, tasks that are
+// already due are executed immediately. It returns the futures of the
+// restored tasks by their IDs.
+func (sched0 *sched[R, T]) Restore() (map[string]*Future[R], error) {
+	if sched0.store == nil {
+		return nil, errors.New("sched: no store is configured")
+	}
+	records, err := sched0.store.LoadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	futures := make(map[string]*Future[R], len(records))
+	sched0.pause()
+	for _, r := range records {
+		if f, ok := sched0.tasks.update(r.Task, r.Execution); ok {
+			futures[r.ID] = f
+			continue
+		}
+		item := newTaskItem[R](r.Task, r.Execution)
+		sched0.track(item)
+		futures[r.ID] = sched0.tasks.push(item)
+	}
+	sched0.resume()
+	return futures, nil
 }
 
 // Pause stops the sched timing
 func (sched0 *sched[R, T]) Pause() {
-	atomic.AddUint64(&sched0.pausing, 1)
+	sched0.pausing.Add(1)
 	sched0.pause()
 }
 
 // Resume resumes sched and start executing tasks
 // this is a pair call with Pause(), Resume() must be called second
 func (sched0 *sched[R, T]) Resume() {
-	atomic.AddUint64(&sched0.pausing, ^uint64(0)) // -1
+	sched0.pausing.Sub(1)
 	sched0.resume()
 }
 
 func (s *sched[R, T]) schedule(t T, when time.Time) *Future[R] {
+	if s.store != nil {
+		err := s.store.Save(Record[T]{ID: t.GetID(), Execution: when, Task: t})
+		if err != nil {
+			f := newFuture[R]()
+			var zero R
+			f.resolve(zero, err)
+			return f
+		}
+	}
+
+	s.opts.observers.OnSubmit(Event{ID: t.GetID(), Execution: when})
 	s.pause()
 
 	// if priority is able to be update
@@ -125,23 +437,33 @@ func (s *sched[R, T]) schedule(t T, when time.Time) *Future[R] {
 		return future
 	}
 
-	future := s.tasks.push(newTaskItem[R](t, when))
+	item := newTaskItem[R](t, when)
+	s.track(item)
+	future := s.tasks.push(item)
 	s.resume()
 	return future
 }
 
 func (s *sched[R, T]) reschedule(t *task[R], when time.Time) {
+	if s.store != nil && t.stream == nil && !when.Equal(t.priority) {
+		// the task is still pending in the store if the save failed,
+		// it is restored at its previous execution time.
+		err := s.store.Save(Record[T]{ID: t.value.GetID(), Execution: when, Task: t.value.(T)})
+		if err != nil {
+			s.opts.errorHandler(&StoreError{Op: "save", ID: t.value.GetID(), Err: err})
+		}
+	}
+
 	s.pause()
 	t.priority = when
 	s.tasks.push(t)
 	s.resume()
 }
 
-func (s *sched[R, T]) getTimer() (t *time.Timer) {
+func (s *sched[R, T]) getTimer() Timer {
 	for {
-		t = (*time.Timer)(atomic.LoadPointer(&s.timer))
-		if t != nil {
-			return
+		if t := s.timer.Load(); t != nil {
+			return *t
 		}
 		runtime.Gosched()
 	}
@@ -150,11 +472,11 @@ func (s *sched[R, T]) getTimer() (t *time.Timer) {
 func (s *sched[R, T]) setTimer(d time.Duration) {
 	for {
 		// fast path: reuse the timer
-		old := atomic.SwapPointer(&s.timer, nil)
+		old := s.timer.Swap(nil)
 		if old != nil {
-			if (*time.Timer)(old).Stop() {
-				(*time.Timer)(old).Reset(d)
-				if atomic.CompareAndSwapPointer(&s.timer, nil, old) {
+			if (*old).Stop() {
+				(*old).Reset(d)
+				if s.timer.CompareAndSwap(nil, old) {
 					return
 				}
 				runtime.Gosched()
@@ -164,43 +486,44 @@ func (s *sched[R, T]) setTimer(d time.Duration) {
 
 		// slow path: fail to stop, use a new timer.
 		// this happens only if the sched is super busy.
-		if atomic.CompareAndSwapPointer(&s.timer, old,
-			unsafe.Pointer(time.NewTimer(d))) {
+		timer := s.opts.clock.NewTimer(d)
+		if s.timer.CompareAndSwap(nil, &timer) {
 			if old != nil {
-				(*time.Timer)(old).Stop()
+				(*old).Stop()
 			}
 			return
 		}
+		timer.Stop()
 		runtime.Gosched()
 	}
 }
 
 // pause pauses sched without pause tasks from running
 func (s *sched[R, T]) pause() {
-	old := atomic.LoadPointer(&s.timer)
+	old := s.timer.Load()
 	// if old is nil then there is someone who tries to stop the timer.
 	if old != nil {
-		(*time.Timer)(old).Stop()
+		(*old).Stop()
 	}
 }
 
 func (s *sched[R, T]) resume() {
 	// Cancel as soon as possible, this must happens before setTimer
 	ctx, cancel := context.WithCancel(context.Background())
-	if x, ok := s.cancel.Load().(context.CancelFunc); ok {
+	if x := s.cancel.Load(); x != nil {
 		x()
 	}
 	s.cancel.Store(cancel)
 
-	t := s.tasks.peek()
-	if t == nil {
+	when, ok := s.tasks.peek()
+	if !ok {
 		return
 	}
-	s.setTimer(t.GetExecution().Sub(time.Now()))
+	s.setTimer(when.Sub(s.opts.clock.Now()))
 
 	go func(ctx context.Context) {
 		select {
-		case <-s.getTimer().C:
+		case <-s.getTimer().C():
 			s.worker()
 		case <-ctx.Done():
 		}
@@ -210,14 +533,16 @@ func (s *sched[R, T]) resume() {
 func (s *sched[R, T]) worker() {
 	// fast path.
 	// if sched requires pausing, then stop executing and resume it.
-	if atomic.LoadUint64(&s.pausing) > 0 {
+	if s.pausing.Load() > 0 {
 		return
 	}
 
 	// medium path.
 	// stop execution if task queue is empty
+	s.active.Add(1)
 	task := s.tasks.pop()
 	if task == nil {
+		s.release()
 		return
 	}
 
@@ -226,33 +551,213 @@ func (s *sched[R, T]) worker() {
 }
 
 func (s *sched[R, T]) arrival(t *task[R]) {
+	// fast path: no limit of concurrency
+	if s.opts.workers <= 0 {
+		s.run(t)
+		return
+	}
+
+	// slow path: the current goroutine becomes a worker if there is a
+	// free slot, otherwise the task waits in the ready queue.
+	s.mu.Lock()
+	if s.workers >= s.opts.workers {
+		s.ready = append(s.ready, t)
+		s.mu.Unlock()
+		return
+	}
+	s.workers++
+	s.mu.Unlock()
+
+	for t != nil {
+		s.run(t)
+
+		s.mu.Lock()
+		if len(s.ready) == 0 {
+			s.workers--
+			s.mu.Unlock()
+			return
+		}
+		t = s.ready[0]
+		s.ready[0] = nil
+		s.ready = s.ready[1:]
+		s.mu.Unlock()
+	}
+}
+
+// queued returns the number of due tasks waiting for a worker
+func (s *sched[R, T]) queued() (n int) {
+	s.mu.Lock()
+	n = len(s.ready)
+	s.mu.Unlock()
+	return
+}
+
+// dequeue removes a due task waiting for a worker by its id.
+func (s *sched[R, T]) dequeue(id string) *task[R] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, t := range s.ready {
+		if t.value.GetID() == id {
+			copy(s.ready[i:], s.ready[i+1:])
+			s.ready[len(s.ready)-1] = nil
+			s.ready = s.ready[:len(s.ready)-1]
+			return t
+		}
+	}
+	return nil
+}
+
+func (s *sched[R, T]) run(t *task[R]) {
 	// record running tasks
-	atomic.AddUint64(&s.running, 1)
+	s.running.Add(1)
 	s.execute(t)
-	atomic.AddUint64(&s.running, ^uint64(0)) // -1
+	s.running.Sub(1)
+	s.release()
+}
+
+// release marks an active task as finished and wakes up Drain.
+func (s *sched[R, T]) release() {
+	s.active.Sub(1)
+	s.notify()
+}
+
+// notify wakes up the goroutines waiting for a task to finish.
+func (s *sched[R, T]) notify() {
+	s.mu.Lock()
+	close(s.changed)
+	s.changed = make(chan struct{})
+	s.mu.Unlock()
+}
+
+// forget deletes a finished task from the store.
+func (s *sched[R, T]) forget(t *task[R]) {
+	if s.store != nil && t.stream == nil {
+		if err := s.store.Delete(t.value.GetID()); err != nil {
+			s.opts.errorHandler(&StoreError{Op: "delete", ID: t.value.GetID(), Err: err})
+		}
+	}
 }
 
 func (s *sched[R, T]) execute(t *task[R]) {
 	defer func() {
 		if r := recover(); r != nil {
-			t.future.Err = fmt.Errorf(
-				"sched: task %s panic while executing, reason: %v",
-				t.value.GetID(), r)
+			err := &PanicError{ID: t.value.GetID(), Value: r, Stack: debug.Stack()}
+			e := t.event()
+			e.Err = err
+			s.opts.observers.OnPanic(e)
+
+			var zero R
+			s.complete(t, zero, err)
 		}
 	}()
+	// started is set only once the task executes in this run
+	t.started = time.Time{}
 
+	// a recurring task may be canceled between its occurrences
+	if t.stream != nil && t.stream.canceled() {
+		var zero R
+		s.complete(t, zero, ErrCanceled)
+		return
+	}
 	// for timer tollerance
-	if t.value.GetExecution().After(time.Now()) {
+	if t.priority.After(s.opts.clock.Now()) {
 		// reschedule task, we must save the task again by using s.Setup
-		s.reschedule(t, t.value.GetExecution())
+		s.reschedule(t, t.priority)
+		return
+	}
+	wait, err := s.dependencies(t)
+	if err != nil {
+		var zero R
+		s.complete(t, zero, err)
+		return
+	}
+	if len(wait) > 0 {
+		s.park(t, wait)
+		return
+	}
+	// the permits are only taken once the lease is held, so that a
+	// task executed by another owner does not consume them.
+	release, ok := s.lease(t)
+	if !ok {
+		return
+	}
+	now := s.opts.clock.Now()
+	if delay := s.limit(t, now); delay > 0 {
+		release(false)
+		s.reschedule(t, now.Add(delay))
 		return
 	}
+	retried := false
+	defer func() { release(!retried) }()
+
+	t.started = s.opts.clock.Now()
+	if t.attempts == 0 {
+		t.first = t.started
+	}
+	e := t.event()
+	e.Lateness = t.started.Sub(e.Execution)
+	s.opts.observers.OnStart(e)
+
 	result, retry, err := t.value.Execute()
 	if retry || err != nil {
-		s.reschedule(t, t.value.GetRetryTime())
+		t.attempts++
+		when, ok := s.retryTime(t, err)
+		if !ok {
+			if err == nil {
+				err = ErrRetryExhausted
+			}
+			var zero R
+			s.complete(t, zero, err)
+			return
+		}
+		e := t.event()
+		e.Next, e.Err = when, err
+		s.opts.observers.OnRetry(e)
+		retried = true
+		s.reschedule(t, when)
 		return
 	}
-	t.future.put(result)
+	s.complete(t, result, nil)
+}
+
+// complete resolves the future of a task, or schedules the next
+// occurrence if the task is recurring.
+func (s *sched[R, T]) complete(t *task[R], result R, err error) {
+	e := t.event()
+	if !t.started.IsZero() {
+		e.Lateness = t.started.Sub(e.Execution)
+		e.Latency = s.opts.clock.Now().Sub(t.started)
+		e.Executed = true
+	}
+	e.Err = err
+	s.opts.observers.OnComplete(e)
+
+	if t.stream == nil {
+		s.forget(t)
+		s.untrack(t, err)
+		t.future.resolve(result, err)
+		return
+	}
+	s.deliver(t, result, err)
+}
+
+// retryTime returns the time of the next execution of a failed task,
+// or false if the retry policy of the task is exhausted.
+func (s *sched[R, T]) retryTime(t *task[R], err error) (time.Time, bool) {
+	p := s.opts.retry
+	if pt, ok := t.value.(RetryPolicyTask); ok {
+		p = pt.GetRetryPolicy()
+	}
+	if p == nil {
+		return t.value.GetRetryTime(), true
+	}
+
+	now := s.opts.clock.Now()
+	delay, ok := p.Next(Attempt{N: t.attempts, First: t.first, Now: now, Err: err})
+	if !ok {
+		return time.Time{}, false
+	}
+	return now.Add(delay), true
 }
 
 // TaskQueue implements a timer queue based on a heap
@@ -305,17 +810,48 @@ func (m *taskQueue[R]) pop() *task[R] {
 	return item
 }
 
-// peek the top priority item without deletion
-func (m *taskQueue[R]) peek() (t Task[R]) {
+// remove item by its id, it also reports if the removed item was
+// the head of the queue.
+func (m *taskQueue[R]) remove(id string) (t *task[R], head bool) {
+	m.mu.Lock()
+	t, ok := m.lookup[id]
+	if !ok {
+		m.mu.Unlock()
+		return nil, false
+	}
+	head = t.index == 0
+	heap.Remove(m.heap, t.index) // O(log(n))
+	delete(m.lookup, id)         // O(1) amortized
+	m.mu.Unlock()
+	return t, head
+}
+
+// snapshot returns the pending items ordered by their time
+func (m *taskQueue[R]) snapshot() []PendingTask {
+	m.mu.Lock()
+	items := make([]PendingTask, len(*m.heap))
+	for i, t := range *m.heap {
+		items[i] = PendingTask{ID: t.value.GetID(), Execution: t.priority}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Execution.Before(items[j].Execution)
+	})
+	return items
+}
+
+// peek the time of the top priority item without deletion
+func (m *taskQueue[R]) peek() (when time.Time, ok bool) {
 	m.mu.Lock()
 
 	if m.heap.Len() == 0 {
 		m.mu.Unlock()
-		return nil
+		return time.Time{}, false
 	}
-	t = (*m.heap)[0].value
+	when = (*m.heap)[0].priority
 	m.mu.Unlock()
-	return
+	return when, true
 }
 
 // update of a given task
@@ -329,6 +865,7 @@ func (m *taskQueue[R]) update(t Task[R], when time.Time) (*Future[R], bool) {
 
 	item.priority = when
 	item.value = t
+	item.rank = priorityOf(t)
 	heap.Fix(m.heap, item.index) // O(log(n))
 	m.mu.Unlock()
 	return item.future, true
@@ -343,11 +880,34 @@ type task[R any] struct {
 	index    int       // The index of the item in the heap.
 	priority time.Time // type of time for priority
 	future   *Future[R]
+
+	attempts int       // number of failed executions
+	first    time.Time // time of the first execution
+	started  time.Time // time of the current execution
+
+	rank   int                   // secondary priority of the task
+	deps   map[string]*Future[R] // futures of dependencies by their IDs
+	needs  []string              // IDs of dependencies counted in dependents
+	unpark chan struct{}         // closed when a parked task is unparked
+
+	// fields of recurring tasks, stream is nil if a task is one-shot.
+	every      Schedule
+	occurrence time.Time // scheduled time of current occurrence
+	stream     *Stream[R]
+}
+
+// event returns an event of the task
+func (t *task[R]) event() Event {
+	e := Event{ID: t.value.GetID(), Execution: t.value.GetExecution(), Attempt: t.attempts}
+	if t.stream != nil {
+		e.Execution = t.occurrence
+	}
+	return e
 }
 
 // NewTaskItem creates a new queue item
 func newTaskItem[R any](t Task[R], when time.Time) *task[R] {
-	return &task[R]{value: t, priority: when, future: &Future[R]{}}
+	return &task[R]{value: t, priority: when, rank: priorityOf(t), future: newFuture[R]()}
 }
 
 type taskHeap[R any] []*task[R]
@@ -357,6 +917,9 @@ func (pq taskHeap[R]) Len() int {
 }
 
 func (pq taskHeap[R]) Less(i, j int) bool {
+	if pq[i].priority.Equal(pq[j].priority) {
+		return pq[i].rank > pq[j].rank
+	}
 	return pq[i].priority.Before(pq[j].priority)
 }
 
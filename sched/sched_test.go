@@ -1,167 +1,272 @@
-package sched
+package sched_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"runtime"
 	"sync"
 	"testing"
 	"time"
-)
-
-// CustomTask implements task.Interface
-type CustomTask struct {
-	Public    string
-	id        string
-	execution time.Time
-}
-
-// NewCustomTask creates a task
-func NewCustomTask(id string, e time.Time) *CustomTask {
-	return &CustomTask{
-		Public:    "not nil",
-		id:        id,
-		execution: e,
-	}
-}
-
-// GetID get task id
-func (t *CustomTask) GetID() (id string) {
-	id = t.id
-	return
-}
-
-// GetExecution get execution time
-func (t *CustomTask) GetExecution() (execute time.Time) {
-	execute = t.execution
-	return
-}
-
-// GetTimeout get timeout of execution
-func (t *CustomTask) GetTimeout() (executeTimeout time.Duration) {
-	return time.Second
-}
-
-// GetRetryTime get retry execution duration
-func (t *CustomTask) GetRetryTime() time.Time {
-	return time.Now().UTC().Add(time.Second)
-}
-
-// SetID sets the id of a task
-func (t *CustomTask) SetID(id string) {
-	t.id = id
-}
-
-// IsValidID check id is valid
-func (t *CustomTask) IsValidID() bool {
-	return true
-}
-
-// SetExecution sets the execution time of a task
-func (t *CustomTask) SetExecution(current time.Time) (old time.Time) {
-	old = t.execution
-	t.execution = current
-	return
-}
 
-// Execute is the actual execution block
-func (t *CustomTask) Execute() (r string, retry bool, fail error) {
-	O.Push(t.id)
-	return fmt.Sprintf("execute task %s.", t.id), false, nil
-}
+	"golang.design/x/go2generics/sched"
+	"golang.design/x/go2generics/sched/schedtest"
+)
 
+var epoch = time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC)
 
 func TestSchedMasiveSchedule(t *testing.T) {
-	O.Clear()
+	sched.O.Clear()
 	nTasks := 100
-	sched0 := NewSched[string, *CustomTask]()
-	futures := make([]*Future[string], nTasks)
+	c := schedtest.NewClock(epoch)
+	sched0 := sched.NewSched[string, *sched.CustomTask](sched.WithClock(c))
+	futures := make([]*sched.Future[string], nTasks)
 
 	defer sched0.Stop()
 	defer sched0.Wait()
 
-	start := time.Now().UTC()
 	expectedOrder := []string{}
 
 	for i := 0; i < nTasks; i++ {
 		key := fmt.Sprintf("task-%d", i)
-		task := NewCustomTask(key, start.Add(time.Millisecond*10*time.Duration(i)))
+		task := sched.NewCustomTask(key, epoch.Add(time.Millisecond*10*time.Duration(i)))
 		expectedOrder = append(expectedOrder, key)
 		future := sched0.Submit(task)
 		futures[i] = future
 	}
 	for i := range futures {
-		fmt.Println(futures[i].Get())
+		if i > 0 {
+			c.BlockUntil(1)
+			c.Advance(10 * time.Millisecond)
+		}
+		futures[i].Get()
 	}
-	if !reflect.DeepEqual(expectedOrder, O.Get()) {
-		t.Errorf("execution order wrong, got: %v", O.Get())
+	if !reflect.DeepEqual(expectedOrder, sched.O.Get()) {
+		t.Errorf("execution order wrong, got: %v", sched.O.Get())
 	}
 }
 
-// O order
-var O = Order{}
+func TestFutureZeroValue(t *testing.T) {
+	c := schedtest.NewClock(epoch)
+	sched0 := sched.NewSched[*int, *sched.FuncTask[*int]](sched.WithClock(c))
+	defer sched0.Stop()
 
-// Order is used for recording execution order
-type Order struct {
-	mu    sync.Mutex
-	order []string
-	first time.Time
-	last  time.Time
+	f := sched0.Submit(sched.NewFuncTask("nil", epoch, func() (*int, bool, error) {
+		return nil, false, nil
+	}))
+	v, err := f.GetTimeout(time.Second)
+	if err != nil || v != nil {
+		t.Fatalf("unexpected result: %v, %v", v, err)
+	}
 }
 
-// Push an execution id
-func (o *Order) Push(s string) {
-	o.mu.Lock()
-	o.order = append(o.order, s)
-	o.mu.Unlock()
-}
+func TestFuturePanic(t *testing.T) {
+	c := schedtest.NewClock(epoch)
+	sched0 := sched.NewSched[int, *sched.FuncTask[int]](sched.WithClock(c))
+	defer sched0.Stop()
 
-// IsFirstZero check if first is zero time
-func (o *Order) IsFirstZero() bool {
-	o.mu.Lock()
-	defer o.mu.Unlock()
-	return o.first.IsZero()
+	f := sched0.Submit(sched.NewFuncTask("panic", epoch, func() (int, bool, error) {
+		panic("boom")
+	}))
+	select {
+	case <-f.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("panicked task never resolves the future")
+	}
+	if v := f.Get(); v != 0 || f.Err == nil {
+		t.Fatalf("unexpected result: %v, %v", v, f.Err)
+	}
 }
 
-// SetFirst time
-func (o *Order) SetFirst(t time.Time) {
-	o.mu.Lock()
-	o.first = t
-	o.mu.Unlock()
-}
+func TestFutureGetContext(t *testing.T) {
+	c := schedtest.NewClock(epoch)
+	sched0 := sched.NewSched[int, *sched.FuncTask[int]](sched.WithClock(c))
+	defer sched0.Stop()
 
-// GetFirst time
-func (o *Order) GetFirst() time.Time {
-	o.mu.Lock()
-	defer o.mu.Unlock()
-	return o.first
+	// the clock never reaches the execution time of the task.
+	f := sched0.Submit(sched.NewFuncTask("later", epoch.Add(time.Hour), func() (int, bool, error) {
+		return 42, false, nil
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := f.GetContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetContext want deadline exceeded, got: %v", err)
+	}
+	if _, err := f.GetTimeout(10 * time.Millisecond); !errors.Is(err, sched.ErrTimeout) {
+		t.Fatalf("GetTimeout want ErrTimeout, got: %v", err)
+	}
 }
 
-// SetLast time
-func (o *Order) SetLast(t time.Time) {
-	o.mu.Lock()
-	o.last = t
-	o.mu.Unlock()
+func TestSchedCancel(t *testing.T) {
+	c := schedtest.NewClock(epoch)
+	sched0 := sched.NewSched[int, *sched.FuncTask[int]](sched.WithClock(c))
+	defer sched0.Stop()
+
+	var executed sync.Map
+	newTask := func(id string, d time.Duration) *sched.FuncTask[int] {
+		return sched.NewFuncTask(id, epoch.Add(d), func() (int, bool, error) {
+			executed.Store(id, true)
+			return 1, false, nil
+		})
+	}
+	head := sched0.Submit(newTask("head", 50*time.Millisecond))
+	tail := sched0.Submit(newTask("tail", 100*time.Millisecond))
+
+	if !sched0.Cancel("head") {
+		t.Fatalf("cancel of a pending task failed")
+	}
+	if sched0.Cancel("head") || sched0.Cancel("unknown") {
+		t.Fatalf("cancel of an unknown task succeeded")
+	}
+	if _, err := head.GetTimeout(time.Second); !errors.Is(err, sched.ErrCanceled) {
+		t.Fatalf("canceled future want ErrCanceled, got: %v", err)
+	}
+	c.BlockUntil(1)
+	c.Advance(100 * time.Millisecond)
+	if v, err := tail.GetTimeout(time.Second); err != nil || v != 1 {
+		t.Fatalf("tail task did not run after head is canceled: %v, %v", v, err)
+	}
+	if _, ok := executed.Load("head"); ok {
+		t.Fatalf("canceled task was executed")
+	}
 }
 
-// GetLast time
-func (o *Order) GetLast() time.Time {
-	o.mu.Lock()
-	defer o.mu.Unlock()
-	return o.last
+func TestSchedWorkers(t *testing.T) {
+	const nWorkers, nTasks = 2, 10
+	c := schedtest.NewClock(epoch)
+	sched0 := sched.NewSched[int, *sched.FuncTask[int]](sched.WithClock(c), sched.WithWorkers(nWorkers))
+	defer sched0.Stop()
+
+	var (
+		mu        sync.Mutex
+		cur, peak int
+	)
+	started := make(chan struct{}, nTasks)
+	release := make(chan struct{})
+	futures := make([]*sched.Future[int], nTasks)
+	for i := 0; i < nTasks; i++ {
+		futures[i] = sched0.Submit(sched.NewFuncTask(fmt.Sprintf("task-%d", i), epoch, func() (int, bool, error) {
+			mu.Lock()
+			cur++
+			if cur > peak {
+				peak = cur
+			}
+			mu.Unlock()
+
+			started <- struct{}{}
+			<-release
+
+			mu.Lock()
+			cur--
+			mu.Unlock()
+			return 1, false, nil
+		}))
+	}
+
+	// all tasks are due, those beyond the workers wait in the queue.
+	for i := 0; i < nWorkers; i++ {
+		<-started
+	}
+	for sched0.Stats().Queued != nTasks-nWorkers {
+		runtime.Gosched()
+	}
+	if st := sched0.Stats(); st.Running != nWorkers {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+
+	close(release)
+	for _, f := range futures {
+		if _, err := f.GetTimeout(time.Second); err != nil {
+			t.Fatalf("task failed: %v", err)
+		}
+	}
+	if peak > nWorkers {
+		t.Fatalf("too many concurrent executions: got %d, want <= %d", peak, nWorkers)
+	}
 }
 
-// Get order
-func (o *Order) Get() []string {
-	o.mu.Lock()
-	defer o.mu.Unlock()
-	return o.order
+func TestSchedCancelQueued(t *testing.T) {
+	c := schedtest.NewClock(epoch)
+	sched0 := sched.NewSched[int, *sched.FuncTask[int]](sched.WithClock(c), sched.WithWorkers(1))
+	defer sched0.Stop()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	sched0.Submit(sched.NewFuncTask("running", epoch, func() (int, bool, error) {
+		close(started)
+		<-release
+		return 1, false, nil
+	}))
+	queued := sched0.Submit(sched.NewFuncTask("queued", epoch, func() (int, bool, error) {
+		t.Errorf("canceled task was executed")
+		return 2, false, nil
+	}))
+	<-started
+	for sched0.Stats().Queued == 0 {
+		runtime.Gosched()
+	}
+
+	if !sched0.Cancel("queued") {
+		t.Fatalf("cancel of a queued task failed")
+	}
+	if _, err := queued.GetTimeout(time.Second); !errors.Is(err, sched.ErrCanceled) {
+		t.Fatalf("canceled future want ErrCanceled, got: %v", err)
+	}
+	if st := sched0.Stats(); st.Queued != 0 {
+		t.Fatalf("canceled task is still queued: %+v", st)
+	}
+	close(release)
+	if err := sched0.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
 }
 
-// Clear the order
-func (o *Order) Clear() {
-	o.mu.Lock()
-	o.order = []string{}
-	o.first = time.Time{}
-	o.last = time.Time{}
-	o.mu.Unlock()
+func TestSchedIntrospection(t *testing.T) {
+	c := schedtest.NewClock(epoch)
+	sched0 := sched.NewSched[int, *sched.FuncTask[int]](sched.WithClock(c))
+	defer sched0.Stop()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	running := sched0.Submit(sched.NewFuncTask("running", epoch, func() (int, bool, error) {
+		close(started)
+		<-release
+		return 1, false, nil
+	}))
+	sched0.Submit(sched.NewFuncTask("b", epoch.Add(time.Hour), func() (int, bool, error) { return 2, false, nil }))
+	sched0.Submit(sched.NewFuncTask("a", epoch.Add(time.Minute), func() (int, bool, error) { return 3, false, nil }))
+
+	<-started
+	if sched0.Len() != 2 {
+		t.Fatalf("want 2 pending tasks, got %d", sched0.Len())
+	}
+	pending := sched0.Pending()
+	if len(pending) != 2 || pending[0].ID != "a" || pending[1].ID != "b" ||
+		!pending[0].Execution.Equal(epoch.Add(time.Minute)) {
+		t.Fatalf("unexpected pending tasks: %v", pending)
+	}
+	if f, ok := sched0.Lookup("running"); !ok || f != running {
+		t.Fatalf("lookup of a running task failed")
+	}
+	if _, ok := sched0.Lookup("unknown"); ok {
+		t.Fatalf("lookup of an unknown task succeeded")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sched0.Drain(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Drain want canceled, got: %v", err)
+	}
+
+	sched0.Cancel("a")
+	sched0.Cancel("b")
+	close(release)
+	if err := sched0.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+	if running.Get() != 1 || sched0.Len() != 0 {
+		t.Fatalf("Drain returns before all tasks are finished")
+	}
+	if _, ok := sched0.Lookup("running"); ok {
+		t.Fatalf("finished task is still known")
+	}
 }
@@ -0,0 +1,160 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package schedtest provides utilities for testing schedulers and
+// tasks of package sched.
+package schedtest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"golang.design/x/go2generics/sched"
+)
+
+// Clock is a manual sched.Clock. Its time only moves by Advance and
+// Set, which deterministically fire the timers that are due.
+type Clock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond // signaled when timers change
+	now    time.Time
+	timers []*timer
+}
+
+// NewClock returns a clock starting at now.
+func NewClock(now time.Time) *Clock {
+	c := &Clock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer creates a timer that fires when the clock advances by d.
+// A timer with a non-positive d fires immediately.
+func (c *Clock) NewTimer(d time.Duration) sched.Timer {
+	t := &timer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d, and fires the timers that are
+// due in the order of their deadlines.
+func (c *Clock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the clock to now, and fires the timers that are due in
+// the order of their deadlines. The clock never goes back.
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	if now.After(c.now) {
+		c.now = now
+	}
+	due := c.dueLocked()
+	c.mu.Unlock()
+
+	for _, d := range due {
+		d.t.fire(d.when)
+	}
+}
+
+// Timers returns the number of active timers.
+func (c *Clock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// BlockUntil blocks until there are at least n active timers. It is
+// useful to wait for a scheduler to arm its timer before Advance.
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// firing is a due timer with its deadline, which is captured with the
+// lock held since the timer may be reset once the lock is released.
+type firing struct {
+	t    *timer
+	when time.Time
+}
+
+// dueLocked removes and returns the active timers that are due.
+func (c *Clock) dueLocked() (due []firing) {
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].when.Before(c.timers[j].when)
+	})
+	i := 0
+	for i < len(c.timers) && !c.timers[i].when.After(c.now) {
+		due = append(due, firing{t: c.timers[i], when: c.timers[i].when})
+		i++
+	}
+	c.timers = append(c.timers[:0], c.timers[i:]...)
+	return due
+}
+
+// removeLocked deactivates a timer, it reports whether the timer was
+// active.
+func (c *Clock) removeLocked(t *timer) bool {
+	for i, tt := range c.timers {
+		if tt == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// timer is a sched.Timer of a Clock.
+type timer struct {
+	clock *Clock
+	c     chan time.Time
+	when  time.Time
+}
+
+func (t *timer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *timer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.removeLocked(t)
+}
+
+func (t *timer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mu.Lock()
+	active := c.removeLocked(t)
+	t.when = c.now.Add(d)
+	if d <= 0 {
+		now := c.now
+		c.mu.Unlock()
+		t.fire(now)
+		return active
+	}
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	c.mu.Unlock()
+	return active
+}
+
+// fire delivers now to the channel of the timer, the value is dropped
+// if the previous one is not received, the same as time.Timer.
+func (t *timer) fire(now time.Time) {
+	select {
+	case t.c <- now:
+	default:
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schedtest_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.design/x/go2generics/sched"
+	"golang.design/x/go2generics/sched/schedtest"
+)
+
+var epoch = time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+// task is a task that executes a given function
+type task struct {
+	id        string
+	execution time.Time
+	fn        func() (string, bool, error)
+}
+
+func (t *task) GetID() string                  { return t.id }
+func (t *task) GetExecution() time.Time        { return t.execution }
+func (t *task) GetRetryTime() time.Time        { return t.execution }
+func (t *task) Execute() (string, bool, error) { return t.fn() }
+func newTask(id string, e time.Time) *task {
+	return &task{id: id, execution: e, fn: func() (string, bool, error) { return id, false, nil }}
+}
+
+func TestClockTimer(t *testing.T) {
+	c := schedtest.NewClock(epoch)
+	t1 := c.NewTimer(time.Minute)
+	t2 := c.NewTimer(time.Hour)
+	t3 := c.NewTimer(2 * time.Minute)
+	if !t3.Stop() || t3.Stop() {
+		t.Fatalf("Stop of an active timer must report true only once")
+	}
+
+	c.Advance(time.Minute)
+	if got := <-t1.C(); !got.Equal(epoch.Add(time.Minute)) {
+		t.Fatalf("timer fires at %v", got)
+	}
+	select {
+	case <-t2.C():
+		t.Fatalf("timer fires too early")
+	case <-t3.C():
+		t.Fatalf("stopped timer fires")
+	default:
+	}
+	if c.Timers() != 1 {
+		t.Fatalf("want 1 active timer, got %d", c.Timers())
+	}
+
+	if !t2.Reset(time.Second) {
+		t.Fatalf("Reset of an active timer must report true")
+	}
+	c.Advance(time.Second)
+	<-t2.C()
+	if !c.Now().Equal(epoch.Add(time.Minute + time.Second)) {
+		t.Fatalf("unexpected time: %v", c.Now())
+	}
+
+	c.NewTimer(0)
+	if c.Timers() != 0 {
+		t.Fatalf("timer of zero duration is still active")
+	}
+}
+
+func TestClockConcurrentReset(t *testing.T) {
+	c := schedtest.NewClock(epoch)
+	tm := c.NewTimer(time.Second)
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				tm.Reset(time.Second)
+			}
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		c.Advance(time.Second)
+		select {
+		case <-tm.C():
+		default:
+		}
+	}
+	close(stop)
+	<-done
+}
+
+func TestSchedFakeClock(t *testing.T) {
+	c := schedtest.NewClock(epoch)
+	s := sched.NewSched[string, *task](sched.WithClock(c))
+	defer s.Stop()
+
+	hour := s.Submit(newTask("hour", epoch.Add(time.Hour)))
+	day := s.Submit(newTask("day", epoch.Add(24*time.Hour)))
+
+	c.BlockUntil(1)
+	c.Advance(time.Hour)
+	if v := hour.Get(); v != "hour" {
+		t.Fatalf("unexpected result: %v", v)
+	}
+	select {
+	case <-day.Done():
+		t.Fatalf("task runs before its execution time")
+	default:
+	}
+
+	c.BlockUntil(1)
+	c.Advance(23 * time.Hour)
+	if v := day.Get(); v != "day" {
+		t.Fatalf("unexpected result: %v", v)
+	}
+}
+
+func TestSchedFakeClockRetry(t *testing.T) {
+	c := schedtest.NewClock(epoch)
+	s := sched.NewSched[string, *task](
+		sched.WithClock(c),
+		sched.WithRetryPolicy(sched.MaxAttempts(3, sched.Exponential(time.Hour, 24*time.Hour, 0))),
+	)
+	defer s.Stop()
+
+	errFail := errors.New("failure")
+	var executions []time.Time
+	tk := newTask("retry", epoch)
+	tk.fn = func() (string, bool, error) {
+		executions = append(executions, c.Now())
+		return "", false, errFail
+	}
+	f := s.Submit(tk)
+
+	for _, d := range []time.Duration{time.Hour, 2 * time.Hour} {
+		c.BlockUntil(1)
+		c.Advance(d)
+	}
+	if _, err := f.GetTimeout(time.Second); !errors.Is(err, errFail) {
+		t.Fatalf("want final error, got: %v", err)
+	}
+	want := []time.Time{epoch, epoch.Add(time.Hour), epoch.Add(3 * time.Hour)}
+	if len(executions) != len(want) {
+		t.Fatalf("unexpected executions: %v", executions)
+	}
+	for i := range want {
+		if !executions[i].Equal(want[i]) {
+			t.Fatalf("unexpected executions: %v", executions)
+		}
+	}
+}
+
+func TestSchedFakeClockCron(t *testing.T) {
+	c := schedtest.NewClock(epoch)
+	s := sched.NewSched[string, *task](sched.WithClock(c))
+	defer s.Stop()
+
+	daily, err := sched.ParseCron("30 9 * * *")
+	if err != nil {
+		t.Fatalf("parse cron failed: %v", err)
+	}
+	stream := s.SubmitRecurring(newTask("daily", time.Time{}), daily)
+	for i := 0; i < 3; i++ {
+		c.BlockUntil(1)
+		c.Advance(24 * time.Hour)
+		r := <-stream.C()
+		if want := epoch.AddDate(0, 0, i).Add(9*time.Hour + 30*time.Minute); !r.Time.Equal(want) {
+			t.Fatalf("occurrence %d at %v, want %v", i, r.Time, want)
+		}
+	}
+	c.BlockUntil(1)
+	if !s.Cancel("daily") {
+		t.Fatalf("cancel failed")
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sched
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Record is a pending task kept in a Store.
+type Record[T any] struct {
+	ID        string
+	Execution time.Time
+	Task      T
+}
+
+// Store persists the pending tasks of a scheduler, so that they can be
+// restored after a restart.
+type Store[T any] interface {
+	// Save saves or replaces the record of a task.
+	Save(r Record[T]) error
+	// Delete deletes the record of a task.
+	Delete(id string) error
+	// LoadAll returns all saved records ordered by execution time.
+	LoadAll() ([]Record[T], error)
+}
+
+// NewStoredSched returns a scheduler like NewSched that persists its
+// pending tasks to st, so that they can be restored by Restore after a
+// restart. Recurring tasks are not persisted.
+func NewStoredSched[R any, T Task[R]](st Store[T], opts ...Option) Scheduler[R, T] {
+	s := newSched[R, T](opts...)
+	s.store = st
+	return s
+}
+
+// StoreError is an error of a Store that happens in the background of
+// a scheduler, it is reported to the error handler of the scheduler.
+// A failed delete means that a finished task is restored again.
+type StoreError struct {
+	// Op is the failed operation, "save" or "delete".
+	Op string
+	// ID is the ID of the task.
+	ID string
+	// Err is the error of the store.
+	Err error
+}
+
+func (e *StoreError) Error() string {
+	return fmt.Sprintf("sched: %s task %s: %v", e.Op, e.ID, e.Err)
+}
+
+func (e *StoreError) Unwrap() error {
+	return e.Err
+}
+
+// Codec serializes tasks of type T.
+type Codec[T any] interface {
+	Encode(t T) ([]byte, error)
+	Decode(b []byte) (T, error)
+}
+
+// JSONCodec is a Codec that serializes tasks by encoding/json.
+type JSONCodec[T any] struct{}
+
+// Encode encodes t to JSON.
+func (JSONCodec[T]) Encode(t T) ([]byte, error) {
+	return json.Marshal(t)
+}
+
+// Decode decodes t from JSON.
+func (JSONCodec[T]) Decode(b []byte) (t T, err error) {
+	err = json.Unmarshal(b, &t)
+	return
+}
+
+// FileStore is a Store backed by an append-only log file. Each Save
+// and Delete appends an entry to the log, and LoadAll replays the log.
+// An incomplete last entry, e.g. written during a crash, is ignored.
+type FileStore[T any] struct {
+	mu    sync.Mutex
+	path  string
+	f     *os.File
+	codec Codec[T]
+}
+
+// logEntry is an entry of the log of a FileStore.
+type logEntry struct {
+	Op        string    `json:"op"`
+	ID        string    `json:"id"`
+	Execution time.Time `json:"execution,omitempty"`
+	Task      []byte    `json:"task,omitempty"`
+}
+
+const (
+	opSave   = "save"
+	opDelete = "delete"
+)
+
+// OpenFileStore opens or creates a log file at path. An incomplete
+// last entry of an existing log is truncated.
+func OpenFileStore[T any](path string, codec Codec[T]) (*FileStore[T], error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if err == nil {
+		err = f.Truncate(int64(bytes.LastIndexByte(b, '\n') + 1))
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &FileStore[T]{path: path, f: f, codec: codec}, nil
+}
+
+// Save appends a save entry to the log.
+func (s *FileStore[T]) Save(r Record[T]) error {
+	b, err := s.codec.Encode(r.Task)
+	if err != nil {
+		return fmt.Errorf("sched: encode task %s: %w", r.ID, err)
+	}
+	return s.append(logEntry{Op: opSave, ID: r.ID, Execution: r.Execution, Task: b})
+}
+
+// Delete appends a delete entry to the log.
+func (s *FileStore[T]) Delete(id string) error {
+	return s.append(logEntry{Op: opDelete, ID: id})
+}
+
+func (s *FileStore[T]) append(e logEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.f.Write(b); err != nil {
+		return err
+	}
+	return s.f.Sync()
+}
+
+// LoadAll replays the log and returns the records that are not deleted.
+func (s *FileStore[T]) LoadAll() ([]Record[T], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+func (s *FileStore[T]) load() ([]Record[T], error) {
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	// every complete entry ends with a newline, so the bytes after the
+	// last newline is an incomplete entry and ignored.
+	lines := bytes.Split(b, []byte{'\n'})
+	entries := map[string]logEntry{}
+	for _, line := range lines[:len(lines)-1] {
+		var e logEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, fmt.Errorf("sched: corrupted log %s: %w", s.path, err)
+		}
+		switch e.Op {
+		case opSave:
+			entries[e.ID] = e
+		case opDelete:
+			delete(entries, e.ID)
+		}
+	}
+
+	records := make([]Record[T], 0, len(entries))
+	for _, e := range entries {
+		t, err := s.codec.Decode(e.Task)
+		if err != nil {
+			return nil, fmt.Errorf("sched: decode task %s: %w", e.ID, err)
+		}
+		records = append(records, Record[T]{ID: e.ID, Execution: e.Execution, Task: t})
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Execution.Before(records[j].Execution)
+	})
+	return records, nil
+}
+
+// Compact rewrites the log so that it only contains the records that
+// are not deleted.
+func (s *FileStore[T]) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, r := range records {
+		t, err := s.codec.Encode(r.Task)
+		if err != nil {
+			return fmt.Errorf("sched: encode task %s: %w", r.ID, err)
+		}
+		b, err := json.Marshal(logEntry{Op: opSave, ID: r.ID, Execution: r.Execution, Task: t})
+		if err != nil {
+			return err
+		}
+		buf.Write(append(b, '\n'))
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	s.f.Close()
+	s.f = f
+	return nil
+}
+
+// Close closes the log file.
+func (s *FileStore[T]) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
@@ -0,0 +1,197 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sched
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// StoredTask is a task that can be encoded by JSONCodec
+type StoredTask struct {
+	ID        string
+	Execution time.Time
+}
+
+// GetID get task id
+func (t *StoredTask) GetID() string { return t.ID }
+
+// GetExecution get execution time
+func (t *StoredTask) GetExecution() time.Time { return t.Execution }
+
+// GetRetryTime get retry execution time
+func (t *StoredTask) GetRetryTime() time.Time { return time.Now() }
+
+// Execute returns the id of the task
+func (t *StoredTask) Execute() (string, bool, error) { return t.ID, false, nil }
+
+func ids[T any](records []Record[T]) []string {
+	s := make([]string, len(records))
+	for i, r := range records {
+		s[i] = r.ID
+	}
+	return s
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.log")
+	st, err := OpenFileStore[*StoredTask](path, JSONCodec[*StoredTask]{})
+	if err != nil {
+		t.Fatalf("open store failed: %v", err)
+	}
+
+	now := time.Now().UTC()
+	for i := 0; i < 5; i++ {
+		task := &StoredTask{ID: fmt.Sprintf("task-%d", i), Execution: now.Add(time.Duration(5-i) * time.Second)}
+		if err := st.Save(Record[*StoredTask]{ID: task.ID, Execution: task.Execution, Task: task}); err != nil {
+			t.Fatalf("save failed: %v", err)
+		}
+	}
+	st.Delete("task-1")
+	st.Delete("task-3")
+	want := []string{"task-4", "task-2", "task-0"}
+
+	records, err := st.LoadAll()
+	if err != nil || !reflect.DeepEqual(ids(records), want) {
+		t.Fatalf("unexpected records: %v, %v", ids(records), err)
+	}
+	if !records[0].Task.Execution.Equal(now.Add(time.Second)) {
+		t.Fatalf("task is not decoded: %+v", records[0].Task)
+	}
+	if err := st.Compact(); err != nil {
+		t.Fatalf("compact failed: %v", err)
+	}
+	st.Close()
+
+	// simulate a crash while writing an entry
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString(`{"op":"delete","id":"ta`)
+	f.Close()
+
+	st, err = OpenFileStore[*StoredTask](path, JSONCodec[*StoredTask]{})
+	if err != nil {
+		t.Fatalf("reopen store failed: %v", err)
+	}
+	defer st.Close()
+	st.Delete("task-2")
+	records, err = st.LoadAll()
+	if err != nil || !reflect.DeepEqual(ids(records), []string{"task-4", "task-0"}) {
+		t.Fatalf("unexpected records after reopen: %v, %v", ids(records), err)
+	}
+}
+
+func TestSchedRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.log")
+	st, err := OpenFileStore[*StoredTask](path, JSONCodec[*StoredTask]{})
+	if err != nil {
+		t.Fatalf("open store failed: %v", err)
+	}
+
+	now := time.Now()
+	sched0 := NewStoredSched[string, *StoredTask](st)
+	done := sched0.Submit(&StoredTask{ID: "done", Execution: now})
+	sched0.Submit(&StoredTask{ID: "soon", Execution: now.Add(50 * time.Millisecond)})
+	sched0.Submit(&StoredTask{ID: "later", Execution: now.Add(time.Hour)})
+	sched0.Submit(&StoredTask{ID: "canceled", Execution: now.Add(time.Hour)})
+	sched0.Cancel("canceled")
+	if _, err := done.GetTimeout(time.Second); err != nil {
+		t.Fatalf("task failed: %v", err)
+	}
+	// crash
+	sched0.Pause()
+	st.Close()
+
+	st, err = OpenFileStore[*StoredTask](path, JSONCodec[*StoredTask]{})
+	if err != nil {
+		t.Fatalf("reopen store failed: %v", err)
+	}
+	defer st.Close()
+	sched1 := NewStoredSched[string, *StoredTask](st)
+	defer sched1.Stop()
+	futures, err := sched1.Restore()
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if len(futures) != 2 || futures["soon"] == nil || futures["later"] == nil {
+		t.Fatalf("unexpected restored tasks: %v", futures)
+	}
+	if v, err := futures["soon"].GetTimeout(time.Second); err != nil || v != "soon" {
+		t.Fatalf("restored task failed: %v, %v", v, err)
+	}
+	if time.Now().Before(now.Add(50 * time.Millisecond)) {
+		t.Fatalf("restored task is executed before its execution time")
+	}
+	records, _ := st.LoadAll()
+	if !reflect.DeepEqual(ids(records), []string{"later"}) {
+		t.Fatalf("executed task is not deleted: %v", ids(records))
+	}
+}
+
+// failingStore is a Store that fails to save after the first save, and
+// fails to delete.
+type failingStore[T any] struct {
+	mu    sync.Mutex
+	saves int
+}
+
+var errStore = errors.New("store failure")
+
+func (s *failingStore[T]) Save(r Record[T]) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.saves++; s.saves > 1 {
+		return errStore
+	}
+	return nil
+}
+
+func (s *failingStore[T]) Delete(id string) error { return errStore }
+
+func (s *failingStore[T]) LoadAll() ([]Record[T], error) { return nil, nil }
+
+func TestSchedStoreErrors(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	sched0 := NewStoredSched[int, *FuncTask[int]](&failingStore[*FuncTask[int]]{}, WithErrorHandler(func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}))
+	defer sched0.Stop()
+
+	retried := false
+	f := sched0.Submit(NewFuncTask("retry", time.Now(), func() (int, bool, error) {
+		if !retried {
+			retried = true
+			return 0, true, nil
+		}
+		return 1, false, nil
+	}))
+	if _, err := f.GetTimeout(time.Second); err != nil {
+		t.Fatalf("task failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var ops []string
+	for _, err := range errs {
+		var serr *StoreError
+		if !errors.As(err, &serr) || serr.ID != "retry" || !errors.Is(err, errStore) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ops = append(ops, serr.Op)
+	}
+	if !reflect.DeepEqual(ops, []string{"save", "delete"}) {
+		t.Fatalf("want the failed save and delete, got: %v", ops)
+	}
+}
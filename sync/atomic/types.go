@@ -0,0 +1,106 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package atomic
+
+import (
+	"sync/atomic"
+	"unsafe"
+
+	"golang.design/x/go2generics/constraints"
+)
+
+// Pointer is an atomic pointer of type *T. The zero value is a nil *T.
+type Pointer[T any] struct {
+	p unsafe.Pointer
+}
+
+func (x *Pointer[T]) Load() *T {
+	return (*T)(atomic.LoadPointer(&x.p))
+}
+
+func (x *Pointer[T]) Store(val *T) {
+	atomic.StorePointer(&x.p, unsafe.Pointer(val))
+}
+
+func (x *Pointer[T]) Swap(new *T) (old *T) {
+	return (*T)(atomic.SwapPointer(&x.p, unsafe.Pointer(new)))
+}
+
+func (x *Pointer[T]) CompareAndSwap(old, new *T) (swapped bool) {
+	return atomic.CompareAndSwapPointer(&x.p, unsafe.Pointer(old), unsafe.Pointer(new))
+}
+
+// Int is an atomic integer of any integer type, signed or unsigned.
+// Arithmetic wraps around as it does for T. The zero value is zero.
+type Int[T constraints.Integer] struct {
+	// v holds T extended to 64 bits. Add may leave high bits that T
+	// does not have, so v is always converted to T before it is used.
+	// On 32-bit platforms, an Int must be 64-bit aligned, e.g. it is
+	// the first field of an allocated struct.
+	v uint64
+}
+
+func (x *Int[T]) Load() T {
+	return T(atomic.LoadUint64(&x.v))
+}
+
+func (x *Int[T]) Store(val T) {
+	atomic.StoreUint64(&x.v, uint64(val))
+}
+
+func (x *Int[T]) Swap(new T) (old T) {
+	return T(atomic.SwapUint64(&x.v, uint64(new)))
+}
+
+func (x *Int[T]) CompareAndSwap(old, new T) (swapped bool) {
+	for {
+		v := atomic.LoadUint64(&x.v)
+		if T(v) != old {
+			return false
+		}
+		if atomic.CompareAndSwapUint64(&x.v, v, uint64(new)) {
+			return true
+		}
+	}
+}
+
+// Add atomically adds delta to x and returns the new value.
+func (x *Int[T]) Add(delta T) (new T) {
+	return T(atomic.AddUint64(&x.v, uint64(delta)))
+}
+
+// Sub atomically subtracts delta from x and returns the new value.
+func (x *Int[T]) Sub(delta T) (new T) {
+	return x.Add(-delta)
+}
+
+// Bool is an atomic boolean. The zero value is false.
+type Bool struct {
+	v uint32
+}
+
+func (x *Bool) Load() bool {
+	return atomic.LoadUint32(&x.v) != 0
+}
+
+func (x *Bool) Store(val bool) {
+	atomic.StoreUint32(&x.v, b32(val))
+}
+
+func (x *Bool) Swap(new bool) (old bool) {
+	return atomic.SwapUint32(&x.v, b32(new)) != 0
+}
+
+func (x *Bool) CompareAndSwap(old, new bool) (swapped bool) {
+	return atomic.CompareAndSwapUint32(&x.v, b32(old), b32(new))
+}
+
+// b32 returns a uint32 0 or 1 representing b.
+func b32(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,91 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package atomic
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestPointer(t *testing.T) {
+	var p Pointer[int]
+	if p.Load() != nil {
+		t.Fatal("zero Pointer is not nil")
+	}
+	a, b := new(int), new(int)
+	p.Store(a)
+	if old := p.Swap(b); old != a {
+		t.Fatalf("Swap returned %p, want %p", old, a)
+	}
+	if p.CompareAndSwap(a, nil) {
+		t.Fatal("CompareAndSwap succeeded with a stale pointer")
+	}
+	if !p.CompareAndSwap(b, nil) || p.Load() != nil {
+		t.Fatal("CompareAndSwap did not store nil")
+	}
+}
+
+func TestIntWrap(t *testing.T) {
+	var i Int[int8]
+	i.Store(127)
+	if v := i.Add(1); v != -128 {
+		t.Fatalf("127+1 = %d, want -128", v)
+	}
+	// The stored bits are not those of int8(-128), but it must still
+	// compare equal to it.
+	if !i.CompareAndSwap(-128, 5) || i.Load() != 5 {
+		t.Fatalf("CompareAndSwap(-128, 5) failed, Load() = %d", i.Load())
+	}
+
+	var u Int[uint16]
+	if v := u.Sub(1); v != 65535 {
+		t.Fatalf("0-1 = %d, want 65535", v)
+	}
+	if old := u.Swap(7); old != 65535 {
+		t.Fatalf("Swap returned %d, want 65535", old)
+	}
+	if u.CompareAndSwap(65535, 0) {
+		t.Fatal("CompareAndSwap succeeded with a stale value")
+	}
+}
+
+func TestIntConcurrent(t *testing.T) {
+	const n = 1 << 12
+
+	var i Int[int32]
+	var wg sync.WaitGroup
+	p := 2 * runtime.GOMAXPROCS(0)
+	for g := 0; g < p; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < n; j++ {
+				i.Add(3)
+				i.Sub(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if v := i.Load(); v != int32(2*n*p) {
+		t.Fatalf("got %d, want %d", v, 2*n*p)
+	}
+}
+
+func TestBool(t *testing.T) {
+	var b Bool
+	if b.Load() {
+		t.Fatal("zero Bool is true")
+	}
+	if b.CompareAndSwap(true, false) {
+		t.Fatal("CompareAndSwap(true, false) succeeded on false")
+	}
+	if !b.CompareAndSwap(false, true) || !b.Load() {
+		t.Fatal("CompareAndSwap(false, true) failed")
+	}
+	if old := b.Swap(false); !old {
+		t.Fatal("Swap returned false, want true")
+	}
+}
@@ -6,16 +6,42 @@ package atomic
 
 import "sync/atomic"
 
-// Value is a type-safe atomic value
+// Value is a type-safe atomic value. The zero value holds the zero
+// value of T.
 type Value[T any] struct {
 	val atomic.Value
 }
 
+// Load returns the value set by the most recent Store, or the zero
+// value of T if there has been no call to Store.
 func (v *Value[T]) Load() T {
-	x := v.val.Load()
-	return x.(T)
+	x, _ := v.val.Load().(T)
+	return x
 }
 
 func (v *Value[T]) Store(val T) {
 	v.val.Store(any(val))
 }
+
+// Swap stores new into Value and returns the previous value, which is
+// the zero value of T if there has been no call to Store.
+func (v *Value[T]) Swap(new T) (old T) {
+	old, _ = v.val.Swap(any(new)).(T)
+	return old
+}
+
+// ComparableValue is a Value of a comparable type, which additionally
+// supports compare-and-swap.
+type ComparableValue[T comparable] struct {
+	Value[T]
+}
+
+// CompareAndSwap executes the compare-and-swap operation for the Value.
+// A Value that was never stored compares equal to the zero value of T.
+func (v *ComparableValue[T]) CompareAndSwap(old, new T) (swapped bool) {
+	var zero T
+	if old == zero && v.val.CompareAndSwap(nil, any(new)) {
+		return true
+	}
+	return v.val.CompareAndSwap(any(old), any(new))
+}
@@ -38,6 +38,36 @@ func TestValueLarge(t *testing.T) {
 	}
 }
 
+func TestValueZero(t *testing.T) {
+	var v Value[*int]
+	if x := v.Load(); x != nil {
+		t.Fatalf("Load of a never stored Value = %v, want nil", x)
+	}
+	var s Value[string]
+	if old := s.Swap("foo"); old != "" {
+		t.Fatalf("Swap of a never stored Value = %q, want \"\"", old)
+	}
+	if old := s.Swap("bar"); old != "foo" {
+		t.Fatalf("Swap = %q, want foo", old)
+	}
+}
+
+func TestValueCompareAndSwap(t *testing.T) {
+	var v ComparableValue[int]
+	if v.CompareAndSwap(1, 2) {
+		t.Fatal("CompareAndSwap(1, 2) succeeded on a never stored Value")
+	}
+	if !v.CompareAndSwap(0, 1) {
+		t.Fatal("CompareAndSwap(0, 1) failed on a never stored Value")
+	}
+	if v.CompareAndSwap(0, 2) {
+		t.Fatal("CompareAndSwap(0, 2) succeeded on a Value holding 1")
+	}
+	if !v.CompareAndSwap(1, 2) || v.Load() != 2 {
+		t.Fatalf("CompareAndSwap(1, 2) did not store 2, Load() = %d", v.Load())
+	}
+}
+
 func TestValuePanic(t *testing.T) {
 	const nilErr = "sync/atomic: store of nil value into Value"
 	const badErr = "sync/atomic: store of inconsistently typed value into Value"
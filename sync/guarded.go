@@ -0,0 +1,109 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sync
+
+import (
+	"context"
+	"sync"
+)
+
+// Guarded is a value of type T protected by a sync.RWMutex. The value is
+// only accessible in the closures passed to Read and Write, which run
+// with the mutex held. The zero value holds the zero value of T.
+//
+// The pointer passed to the closures must not be retained after they
+// return.
+type Guarded[T any] struct {
+	mu sync.RWMutex
+	v  T
+}
+
+// NewGuarded returns a Guarded holding v.
+func NewGuarded[T any](v T) *Guarded[T] {
+	return &Guarded[T]{v: v}
+}
+
+// Read calls f with the value while holding the read lock. f must not
+// modify the value.
+func (g *Guarded[T]) Read(f func(v *T)) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	f(&g.v)
+}
+
+// Write calls f with the value while holding the write lock.
+func (g *Guarded[T]) Write(f func(v *T)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	f(&g.v)
+}
+
+// Watch is a Guarded value whose changes can be waited for. The zero
+// value holds the zero value of T.
+type Watch[T any] struct {
+	g Guarded[T]
+
+	once sync.Once
+	cond *sync.Cond
+}
+
+// NewWatch returns a Watch holding v.
+func NewWatch[T any](v T) *Watch[T] {
+	return &Watch[T]{g: Guarded[T]{v: v}}
+}
+
+func (w *Watch[T]) init() {
+	w.once.Do(func() {
+		w.cond = sync.NewCond(&w.g.mu)
+	})
+}
+
+// Read calls f with the value while holding the read lock. f must not
+// modify the value.
+func (w *Watch[T]) Read(f func(v *T)) {
+	w.g.Read(f)
+}
+
+// Write calls f with the value while holding the write lock, then wakes
+// up the goroutines waiting in Wait to check their predicates.
+func (w *Watch[T]) Write(f func(v *T)) {
+	w.init()
+	w.g.Write(f)
+	w.cond.Broadcast()
+}
+
+// Wait blocks until pred reports true for the value and returns a copy
+// of the value that satisfied it. pred is called with the write lock
+// held, once at first and then after every Write. If ctx is done first,
+// Wait returns ctx.Err().
+func (w *Watch[T]) Wait(ctx context.Context, pred func(v *T) bool) (T, error) {
+	w.init()
+	if ctx.Done() != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		// Take the lock to broadcast so that the wakeup cannot be lost
+		// between the check of ctx and the wait below.
+		go func() {
+			select {
+			case <-ctx.Done():
+				w.g.mu.Lock()
+				defer w.g.mu.Unlock()
+				w.cond.Broadcast()
+			case <-stop:
+			}
+		}()
+	}
+
+	w.g.mu.Lock()
+	defer w.g.mu.Unlock()
+	for !pred(&w.g.v) {
+		if err := ctx.Err(); err != nil {
+			var zero T
+			return zero, err
+		}
+		w.cond.Wait()
+	}
+	return w.g.v, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sync
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGuarded(t *testing.T) {
+	g := NewGuarded(map[string]int{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				g.Write(func(m *map[string]int) { (*m)["n"]++ })
+				g.Read(func(m *map[string]int) { _ = (*m)["n"] })
+			}
+		}()
+	}
+	wg.Wait()
+
+	g.Read(func(m *map[string]int) {
+		if n := (*m)["n"]; n != 800 {
+			t.Fatalf("got %d, want 800", n)
+		}
+	})
+}
+
+func TestWatch(t *testing.T) {
+	var w Watch[int]
+
+	done := make(chan int)
+	go func() {
+		v, err := w.Wait(context.Background(), func(v *int) bool { return *v >= 3 })
+		if err != nil {
+			t.Errorf("Wait failed: %v", err)
+		}
+		done <- v
+	}()
+
+	for i := 0; i < 5; i++ {
+		w.Write(func(v *int) { *v++ })
+	}
+	if v := <-done; v < 3 {
+		t.Fatalf("Wait returned %d, which does not satisfy the predicate", v)
+	}
+
+	// A predicate that already holds does not block.
+	v, err := w.Wait(context.Background(), func(v *int) bool { return *v == 5 })
+	if v != 5 || err != nil {
+		t.Fatalf("Wait = %v, %v, want 5, <nil>", v, err)
+	}
+}
+
+func TestWatchContext(t *testing.T) {
+	w := NewWatch("idle")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := w.Wait(ctx, func(v *string) bool { return *v == "ready" })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// The watch still works after a canceled wait.
+	go w.Write(func(v *string) { *v = "ready" })
+	v, err := w.Wait(context.Background(), func(v *string) bool { return *v == "ready" })
+	if v != "ready" || err != nil {
+		t.Fatalf("Wait = %q, %v, want ready, <nil>", v, err)
+	}
+}
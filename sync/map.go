@@ -12,25 +12,30 @@ import (
 
 // Map is a type-safe concurrent-safe map[k]v container.
 type Map[K comparable, V any] struct {
+	size   int64 // number of live entries, accessed atomically
 	mu     sync.Mutex
 	read   atomic.Value // readOnly
-	dirty  map[K]*entry
+	dirty  map[K]*entry[V]
 	misses int
 }
 
 type readOnly[K comparable, V any] struct {
-	m       map[K]*entry
+	m       map[K]*entry[V]
 	amended bool // true if the dirty map contains some key not in m.
 }
 
-var expunged = unsafe.Pointer(new(any))
+// expunged is an arbitrary pointer that marks entries which have been
+// deleted from the dirty map. It is never dereferenced, so it is shared
+// by entries of all value types.
+var expunged = unsafe.Pointer(new(byte))
 
-type entry struct {
-	p unsafe.Pointer // *any
+// entry holds a value of type V without boxing it into an interface.
+type entry[V any] struct {
+	p unsafe.Pointer // *V
 }
 
-func newEntry(i any) *entry {
-	return &entry{p: unsafe.Pointer(&i)}
+func newEntry[V any](v V) *entry[V] {
+	return &entry[V]{p: unsafe.Pointer(&v)}
 }
 
 func (m *Map[K, V]) Load(key K) (value V, ok bool) {
@@ -49,20 +54,15 @@ func (m *Map[K, V]) Load(key K) (value V, ok bool) {
 	if !ok {
 		return
 	}
-	v, loaded := e.load()
-	ok = loaded
-	if ok {
-		value = v.(V)
-	}
-	return
+	return e.load()
 }
 
-func (e *entry) load() (value any, ok bool) {
+func (e *entry[V]) load() (value V, ok bool) {
 	p := atomic.LoadPointer(&e.p)
 	if p == nil || p == expunged {
-		return nil, false
+		return value, false
 	}
-	return *(*any)(p), true
+	return *(*V)(p), true
 }
 
 func (m *Map[K, V]) missLocked() {
@@ -94,24 +94,21 @@ func (m *Map[K, V]) LoadAndDelete(key K) (value V, loaded bool) {
 		m.mu.Unlock()
 	}
 	if ok {
-		v, ok := e.delete()
-		if ok {
-			value = v.(V)
+		if value, loaded = e.delete(); loaded {
+			atomic.AddInt64(&m.size, -1)
 		}
-		loaded = ok
-		return
 	}
-	return
+	return value, loaded
 }
 
-func (e *entry) delete() (value any, ok bool) {
+func (e *entry[V]) delete() (value V, ok bool) {
 	for {
 		p := atomic.LoadPointer(&e.p)
 		if p == nil || p == expunged {
-			return nil, false
+			return value, false
 		}
 		if atomic.CompareAndSwapPointer(&e.p, p, nil) {
-			return *(*any)(p), true
+			return *(*V)(p), true
 		}
 	}
 }
@@ -121,7 +118,10 @@ func (m *Map[K, V]) LoadOrStore(key K, value V) (V, bool) {
 	if e, ok := read.m[key]; ok {
 		actual, loaded, ok := e.tryLoadOrStore(value)
 		if ok {
-			return actual.(V), loaded
+			if !loaded {
+				atomic.AddInt64(&m.size, 1)
+			}
+			return actual, loaded
 		}
 	}
 
@@ -132,50 +132,57 @@ func (m *Map[K, V]) LoadOrStore(key K, value V) (V, bool) {
 			m.dirty[key] = e
 		}
 		actual, loaded, _ := e.tryLoadOrStore(value)
+		if !loaded {
+			atomic.AddInt64(&m.size, 1)
+		}
 		m.mu.Unlock()
-		return actual.(V), loaded
+		return actual, loaded
 	} else if e, ok := m.dirty[key]; ok {
 		actual, loaded, _ := e.tryLoadOrStore(value)
+		if !loaded {
+			atomic.AddInt64(&m.size, 1)
+		}
 		m.missLocked()
 		m.mu.Unlock()
-		return actual.(V), loaded
+		return actual, loaded
 	} else {
 		if !read.amended {
 			m.dirtyLocked()
 			m.read.Store(readOnly[K, V]{m: read.m, amended: true})
 		}
 		m.dirty[key] = newEntry(value)
+		atomic.AddInt64(&m.size, 1)
 		actual, loaded := value, false
 		m.mu.Unlock()
 		return actual, loaded
 	}
 }
 
-func (e *entry) tryLoadOrStore(i any) (actual any, loaded, ok bool) {
+func (e *entry[V]) tryLoadOrStore(v V) (actual V, loaded, ok bool) {
 	p := atomic.LoadPointer(&e.p)
 	if p == expunged {
-		return nil, false, false
+		return actual, false, false
 	}
 	if p != nil {
-		return *(*any)(p), true, true
+		return *(*V)(p), true, true
 	}
 
-	ic := i
+	vc := v
 	for {
-		if atomic.CompareAndSwapPointer(&e.p, nil, unsafe.Pointer(&ic)) {
-			return i, false, true
+		if atomic.CompareAndSwapPointer(&e.p, nil, unsafe.Pointer(&vc)) {
+			return v, false, true
 		}
 		p = atomic.LoadPointer(&e.p)
 		if p == expunged {
-			return nil, false, false
+			return actual, false, false
 		}
 		if p != nil {
-			return *(*any)(p), true, true
+			return *(*V)(p), true, true
 		}
 	}
 }
 
-func (e *entry) unexpungeLocked() (wasExpunged bool) {
+func (e *entry[V]) unexpungeLocked() (wasExpunged bool) {
 	return atomic.CompareAndSwapPointer(&e.p, expunged, nil)
 }
 
@@ -185,7 +192,7 @@ func (m *Map[K, V]) dirtyLocked() {
 	}
 
 	read, _ := m.read.Load().(readOnly[K, V])
-	m.dirty = make(map[K]*entry, len(read.m))
+	m.dirty = make(map[K]*entry[V], len(read.m))
 	for k, e := range read.m {
 		if !e.tryExpungeLocked() {
 			m.dirty[k] = e
@@ -193,7 +200,7 @@ func (m *Map[K, V]) dirtyLocked() {
 	}
 }
 
-func (e *entry) tryExpungeLocked() (isExpunged bool) {
+func (e *entry[V]) tryExpungeLocked() (isExpunged bool) {
 	p := atomic.LoadPointer(&e.p)
 	for p == nil {
 		if atomic.CompareAndSwapPointer(&e.p, nil, expunged) {
@@ -231,17 +238,24 @@ func (m *Map[K, V]) Range(f func(key K, value V) bool) {
 		if !ok {
 			continue
 		}
-		if !f(k, v.(V)) {
+		if !f(k, v) {
 			break
 		}
 	}
 }
 
 func (m *Map[K, V]) Store(key K, value V) {
+	m.Swap(key, value)
+}
+
+// Swap stores value for key and returns the previous value if any.
+// The loaded result reports whether the key was present.
+func (m *Map[K, V]) Swap(key K, value V) (previous V, loaded bool) {
 	read, _ := m.read.Load().(readOnly[K, V])
-	vv := any(value)
-	if e, ok := read.m[key]; ok && e.tryStore(&vv) {
-		return
+	if e, ok := read.m[key]; ok {
+		if p, ok := e.trySwap(&value); ok {
+			return m.swapped(p)
+		}
 	}
 
 	m.mu.Lock()
@@ -250,33 +264,293 @@ func (m *Map[K, V]) Store(key K, value V) {
 		if e.unexpungeLocked() {
 			m.dirty[key] = e
 		}
-		vv := any(value)
-		e.storeLocked(&vv)
+		previous, loaded = m.swapped(e.swapLocked(&value))
 	} else if e, ok := m.dirty[key]; ok {
-		vv := any(value)
-		e.storeLocked(&vv)
+		previous, loaded = m.swapped(e.swapLocked(&value))
 	} else {
 		if !read.amended {
 			m.dirtyLocked()
 			m.read.Store(readOnly[K, V]{m: read.m, amended: true})
 		}
 		m.dirty[key] = newEntry(value)
+		atomic.AddInt64(&m.size, 1)
 	}
 	m.mu.Unlock()
+	return previous, loaded
+}
+
+// swapped converts the pointer replaced by a swap into its value and
+// accounts for a newly live entry.
+func (m *Map[K, V]) swapped(p unsafe.Pointer) (previous V, loaded bool) {
+	if p == nil {
+		atomic.AddInt64(&m.size, 1)
+		return previous, false
+	}
+	return *(*V)(p), true
 }
 
-func (e *entry) tryStore(i *any) bool {
+// trySwap swaps a value if the entry has not been expunged.
+func (e *entry[V]) trySwap(v *V) (previous unsafe.Pointer, ok bool) {
 	for {
 		p := atomic.LoadPointer(&e.p)
 		if p == expunged {
+			return nil, false
+		}
+		if atomic.CompareAndSwapPointer(&e.p, p, unsafe.Pointer(v)) {
+			return p, true
+		}
+	}
+}
+
+// swapLocked unconditionally swaps a value into the entry.
+// The entry must be known not to be expunged.
+func (e *entry[V]) swapLocked(v *V) unsafe.Pointer {
+	return atomic.SwapPointer(&e.p, unsafe.Pointer(v))
+}
+
+// Len returns the number of keys in the map. It runs in constant time.
+func (m *Map[K, V]) Len() int {
+	return int(atomic.LoadInt64(&m.size))
+}
+
+// Clear deletes all the keys.
+func (m *Map[K, V]) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	read, _ := m.read.Load().(readOnly[K, V])
+	if len(read.m) == 0 && !read.amended {
+		return
+	}
+	// Expunge the old entries so that operations still holding the
+	// previous read map fall through to the slow path and see them as
+	// absent, which keeps size exact.
+	for _, e := range read.m {
+		m.expungeLocked(e)
+	}
+	for _, e := range m.dirty {
+		m.expungeLocked(e)
+	}
+	m.read.Store(readOnly[K, V]{})
+	m.dirty = nil
+	m.misses = 0
+}
+
+func (m *Map[K, V]) expungeLocked(e *entry[V]) {
+	p := atomic.SwapPointer(&e.p, expunged)
+	if p != nil && p != expunged {
+		atomic.AddInt64(&m.size, -1)
+	}
+}
+
+// ComparableMap is a Map whose values are comparable, which additionally
+// supports compare-and-swap operations. Its zero value is empty and ready
+// for use.
+type ComparableMap[K, V comparable] struct {
+	Map[K, V]
+}
+
+// CompareAndSwap swaps the old and new values for key if the value
+// stored in the map is equal to old.
+func (m *ComparableMap[K, V]) CompareAndSwap(key K, old, new V) (swapped bool) {
+	read, _ := m.read.Load().(readOnly[K, V])
+	if e, ok := read.m[key]; ok {
+		return tryCompareAndSwap(e, old, new)
+	} else if !read.amended {
+		return false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	read, _ = m.read.Load().(readOnly[K, V])
+	if e, ok := read.m[key]; ok {
+		swapped = tryCompareAndSwap(e, old, new)
+	} else if e, ok := m.dirty[key]; ok {
+		swapped = tryCompareAndSwap(e, old, new)
+		m.missLocked()
+	}
+	return swapped
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+func (m *ComparableMap[K, V]) CompareAndDelete(key K, old V) (deleted bool) {
+	read, _ := m.read.Load().(readOnly[K, V])
+	e, ok := read.m[key]
+	if !ok && read.amended {
+		m.mu.Lock()
+		read, _ = m.read.Load().(readOnly[K, V])
+		e, ok = read.m[key]
+		if !ok && read.amended {
+			e, ok = m.dirty[key]
+			m.missLocked()
+		}
+		m.mu.Unlock()
+	}
+	for ok {
+		p := atomic.LoadPointer(&e.p)
+		if p == nil || p == expunged || *(*V)(p) != old {
 			return false
 		}
-		if atomic.CompareAndSwapPointer(&e.p, p, unsafe.Pointer(i)) {
+		if atomic.CompareAndSwapPointer(&e.p, p, nil) {
+			atomic.AddInt64(&m.size, -1)
 			return true
 		}
 	}
+	return false
 }
 
-func (e *entry) storeLocked(i *any) {
-	atomic.StorePointer(&e.p, unsafe.Pointer(i))
+// tryCompareAndSwap compares the entry with the given old value and swaps
+// it with a new value if the entry is equal to the old value and has not
+// been expunged.
+func tryCompareAndSwap[V comparable](e *entry[V], old, new V) bool {
+	p := atomic.LoadPointer(&e.p)
+	if p == nil || p == expunged || *(*V)(p) != old {
+		return false
+	}
+	nc := new
+	for {
+		if atomic.CompareAndSwapPointer(&e.p, p, unsafe.Pointer(&nc)) {
+			return true
+		}
+		p = atomic.LoadPointer(&e.p)
+		if p == nil || p == expunged || *(*V)(p) != old {
+			return false
+		}
+	}
+}
+
+// Compute atomically replaces the value for key with the result of f,
+// which is passed the current value and whether it was present. If f
+// reports delete, the key is removed instead. Compute returns the value
+// now stored and whether the key is present.
+//
+// Each Compute is linearizable with respect to the other operations on
+// the map. Under contention f may be called more than once, but only the
+// result of the last call is installed, and it was computed from the
+// value it replaces. f may run while the map is locked for writers, so
+// it must not access the map and should be fast.
+func (m *Map[K, V]) Compute(key K, f func(old V, loaded bool) (new V, delete bool)) (actual V, ok bool) {
+	read, _ := m.read.Load().(readOnly[K, V])
+	if e, ok := read.m[key]; ok {
+		if actual, ok, done := m.tryCompute(e, f); done {
+			return actual, ok
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	read, _ = m.read.Load().(readOnly[K, V])
+	if e, found := read.m[key]; found {
+		if e.unexpungeLocked() {
+			m.dirty[key] = e
+		}
+		actual, ok, _ = m.tryCompute(e, f)
+	} else if e, found := m.dirty[key]; found {
+		actual, ok, _ = m.tryCompute(e, f)
+		m.missLocked()
+	} else {
+		var zero V
+		v, del := f(zero, false)
+		if del {
+			return actual, false
+		}
+		if !read.amended {
+			m.dirtyLocked()
+			m.read.Store(readOnly[K, V]{m: read.m, amended: true})
+		}
+		m.dirty[key] = newEntry(v)
+		atomic.AddInt64(&m.size, 1)
+		actual, ok = v, true
+	}
+	return actual, ok
+}
+
+// tryCompute installs the result of f into the entry. It reports done as
+// false, without calling f, if the entry has been expunged.
+func (m *Map[K, V]) tryCompute(e *entry[V], f func(V, bool) (V, bool)) (actual V, ok, done bool) {
+	for {
+		p := atomic.LoadPointer(&e.p)
+		if p == expunged {
+			return actual, false, false
+		}
+		var old V
+		if p != nil {
+			old = *(*V)(p)
+		}
+		v, del := f(old, p != nil)
+		if del {
+			if p == nil {
+				return actual, false, true
+			}
+			if atomic.CompareAndSwapPointer(&e.p, p, nil) {
+				atomic.AddInt64(&m.size, -1)
+				return actual, false, true
+			}
+			continue
+		}
+		if atomic.CompareAndSwapPointer(&e.p, p, unsafe.Pointer(&v)) {
+			if p == nil {
+				atomic.AddInt64(&m.size, 1)
+			}
+			return v, true, true
+		}
+	}
+}
+
+// LoadOrCompute returns the existing value for key if present. Otherwise,
+// it stores and returns the value returned by new. The loaded result is
+// true if the value was loaded, false if stored.
+//
+// Concurrent callers of LoadOrCompute for the same missing key call new
+// at most once between them. new runs while the map is locked for
+// writers, so it must not access the map and should be fast.
+func (m *Map[K, V]) LoadOrCompute(key K, new func() V) (actual V, loaded bool) {
+	if v, ok := m.Load(key); ok {
+		return v, true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	read, _ := m.read.Load().(readOnly[K, V])
+	if e, ok := read.m[key]; ok {
+		if e.unexpungeLocked() {
+			m.dirty[key] = e
+		}
+		return m.loadOrComputeLocked(e, new)
+	} else if e, ok := m.dirty[key]; ok {
+		m.missLocked()
+		return m.loadOrComputeLocked(e, new)
+	}
+	if !read.amended {
+		m.dirtyLocked()
+		m.read.Store(readOnly[K, V]{m: read.m, amended: true})
+	}
+	actual = new()
+	m.dirty[key] = newEntry(actual)
+	atomic.AddInt64(&m.size, 1)
+	return actual, false
+}
+
+func (m *Map[K, V]) loadOrComputeLocked(e *entry[V], new func() V) (actual V, loaded bool) {
+	if v, ok := e.load(); ok {
+		return v, true
+	}
+	actual, loaded, _ = e.tryLoadOrStore(new())
+	if !loaded {
+		atomic.AddInt64(&m.size, 1)
+	}
+	return actual, loaded
+}
+
+// Update atomically replaces the value for key with f applied to it, if
+// the key is present. It returns the new value and whether the key was
+// present. Like Compute, f may be called more than once under contention
+// and may run while the map is locked, so it must not access the map.
+func (m *Map[K, V]) Update(key K, f func(old V) V) (value V, ok bool) {
+	return m.Compute(key, func(old V, loaded bool) (V, bool) {
+		if !loaded {
+			return old, true
+		}
+		return f(old), false
+	})
 }
@@ -11,18 +11,23 @@ import (
 
 // This file contains reference map implementations for unit-tests.
 
-// mapInterface is the interface Map implements.
-type mapInterface[K comparable, V any] interface {
+// mapInterface is the interface ComparableMap implements.
+type mapInterface[K, V comparable] interface {
 	Load(K) (V, bool)
 	Store(K, V)
 	LoadOrStore(K, V) (actual V, loaded bool)
 	LoadAndDelete(K) (actual V, loaded bool)
 	Delete(K)
+	Swap(K, V) (previous V, loaded bool)
+	CompareAndSwap(key K, old, new V) (swapped bool)
+	CompareAndDelete(key K, old V) (deleted bool)
+	Len() int
+	Clear()
 	Range(func(K, V) (shouldContinue bool))
 }
 
 // RWMutexMap is an implementation of mapInterface using a sync.RWMutex.
-type RWMutexMap[K comparable, V any] struct {
+type RWMutexMap[K, V comparable] struct {
 	mu    sync.RWMutex
 	dirty map[K]V
 }
@@ -75,6 +80,49 @@ func (m *RWMutexMap[K, V]) Delete(key K) {
 	m.mu.Unlock()
 }
 
+func (m *RWMutexMap[K, V]) Swap(key K, value V) (previous V, loaded bool) {
+	m.mu.Lock()
+	if m.dirty == nil {
+		m.dirty = make(map[K]V)
+	}
+	previous, loaded = m.dirty[key]
+	m.dirty[key] = value
+	m.mu.Unlock()
+	return
+}
+
+func (m *RWMutexMap[K, V]) CompareAndSwap(key K, old, new V) (swapped bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if value, ok := m.dirty[key]; !ok || value != old {
+		return false
+	}
+	m.dirty[key] = new
+	return true
+}
+
+func (m *RWMutexMap[K, V]) CompareAndDelete(key K, old V) (deleted bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if value, ok := m.dirty[key]; !ok || value != old {
+		return false
+	}
+	delete(m.dirty, key)
+	return true
+}
+
+func (m *RWMutexMap[K, V]) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.dirty)
+}
+
+func (m *RWMutexMap[K, V]) Clear() {
+	m.mu.Lock()
+	m.dirty = nil
+	m.mu.Unlock()
+}
+
 func (m *RWMutexMap[K, V]) Range(f func(key K, value V) (shouldContinue bool)) {
 	m.mu.RLock()
 	keys := make([]K, 0, len(m.dirty))
@@ -97,7 +145,7 @@ func (m *RWMutexMap[K, V]) Range(f func(key K, value V) (shouldContinue bool)) {
 // DeepCopyMap is an implementation of mapInterface using a Mutex and
 // atomic.Value.  It makes deep copies of the map on every write to avoid
 // acquiring the Mutex in Load.
-type DeepCopyMap[K comparable, V any] struct {
+type DeepCopyMap[K, V comparable] struct {
 	mu    sync.Mutex
 	clean atomic.Value
 }
@@ -155,6 +203,61 @@ func (m *DeepCopyMap[K, V]) Delete(key K) {
 	m.mu.Unlock()
 }
 
+func (m *DeepCopyMap[K, V]) Swap(key K, value V) (previous V, loaded bool) {
+	m.mu.Lock()
+	dirty := m.dirty()
+	previous, loaded = dirty[key]
+	dirty[key] = value
+	m.clean.Store(dirty)
+	m.mu.Unlock()
+	return
+}
+
+func (m *DeepCopyMap[K, V]) CompareAndSwap(key K, old, new V) (swapped bool) {
+	clean, _ := m.clean.Load().(map[K]V)
+	if value, ok := clean[key]; !ok || value != old {
+		return false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dirty := m.dirty()
+	if value, ok := dirty[key]; !ok || value != old {
+		return false
+	}
+	dirty[key] = new
+	m.clean.Store(dirty)
+	return true
+}
+
+func (m *DeepCopyMap[K, V]) CompareAndDelete(key K, old V) (deleted bool) {
+	clean, _ := m.clean.Load().(map[K]V)
+	if value, ok := clean[key]; !ok || value != old {
+		return false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dirty := m.dirty()
+	if value, ok := dirty[key]; !ok || value != old {
+		return false
+	}
+	delete(dirty, key)
+	m.clean.Store(dirty)
+	return true
+}
+
+func (m *DeepCopyMap[K, V]) Len() int {
+	clean, _ := m.clean.Load().(map[K]V)
+	return len(clean)
+}
+
+func (m *DeepCopyMap[K, V]) Clear() {
+	m.mu.Lock()
+	m.clean.Store(map[K]V{})
+	m.mu.Unlock()
+}
+
 func (m *DeepCopyMap[K, V]) Range(f func(key K, value V) (shouldContinue bool)) {
 	clean, _ := m.clean.Load().(map[K]V)
 	for k, v := range clean {
@@ -172,3 +275,96 @@ func (m *DeepCopyMap[K, V]) dirty() map[K]V {
 	}
 	return dirty
 }
+
+// StdMap is an implementation of mapInterface using the standard
+// sync.Map, which boxes values into interfaces. The sync.Map of Go 1.18
+// has no Swap, CompareAndSwap, CompareAndDelete or Clear, so these are
+// emulated under mu and are only atomic with respect to each other.
+type StdMap[K, V comparable] struct {
+	mu sync.Mutex
+	m  sync.Map
+}
+
+func (m *StdMap[K, V]) Load(key K) (value V, ok bool) {
+	v, ok := m.m.Load(key)
+	if ok {
+		value = v.(V)
+	}
+	return value, ok
+}
+
+func (m *StdMap[K, V]) Store(key K, value V) {
+	m.m.Store(key, value)
+}
+
+func (m *StdMap[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
+	v, loaded := m.m.LoadOrStore(key, value)
+	return v.(V), loaded
+}
+
+func (m *StdMap[K, V]) LoadAndDelete(key K) (value V, loaded bool) {
+	v, loaded := m.m.LoadAndDelete(key)
+	if loaded {
+		value = v.(V)
+	}
+	return value, loaded
+}
+
+func (m *StdMap[K, V]) Delete(key K) {
+	m.m.Delete(key)
+}
+
+func (m *StdMap[K, V]) Swap(key K, value V) (previous V, loaded bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, loaded := m.m.Load(key)
+	if loaded {
+		previous = v.(V)
+	}
+	m.m.Store(key, value)
+	return previous, loaded
+}
+
+func (m *StdMap[K, V]) CompareAndSwap(key K, old, new V) (swapped bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.m.Load(key); !ok || v.(V) != old {
+		return false
+	}
+	m.m.Store(key, new)
+	return true
+}
+
+func (m *StdMap[K, V]) CompareAndDelete(key K, old V) (deleted bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.m.Load(key); !ok || v.(V) != old {
+		return false
+	}
+	m.m.Delete(key)
+	return true
+}
+
+// Len counts the keys with Range, since sync.Map does not track its size.
+func (m *StdMap[K, V]) Len() (n int) {
+	m.m.Range(func(_, _ any) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+func (m *StdMap[K, V]) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.m.Range(func(k, _ any) bool {
+		m.m.Delete(k)
+		return true
+	})
+}
+
+func (m *StdMap[K, V]) Range(f func(key K, value V) (shouldContinue bool)) {
+	m.m.Range(func(k, v any) bool {
+		return f(k.(K), v.(V))
+	})
+}
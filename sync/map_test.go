@@ -5,10 +5,13 @@
 package sync
 
 import (
+	"fmt"
 	"math/rand"
 	"reflect"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"testing/quick"
 )
@@ -16,20 +19,29 @@ import (
 type mapOp string
 
 const (
-	opLoad          = mapOp("Load")
-	opStore         = mapOp("Store")
-	opLoadOrStore   = mapOp("LoadOrStore")
-	opLoadAndDelete = mapOp("LoadAndDelete")
-	opDelete        = mapOp("Delete")
+	opLoad             = mapOp("Load")
+	opStore            = mapOp("Store")
+	opLoadOrStore      = mapOp("LoadOrStore")
+	opLoadAndDelete    = mapOp("LoadAndDelete")
+	opDelete           = mapOp("Delete")
+	opSwap             = mapOp("Swap")
+	opCompareAndSwap   = mapOp("CompareAndSwap")
+	opCompareAndDelete = mapOp("CompareAndDelete")
+	opLen              = mapOp("Len")
+	opClear            = mapOp("Clear")
 )
 
-var mapOps = [...]mapOp{opLoad, opStore, opLoadOrStore, opLoadAndDelete, opDelete}
+var mapOps = [...]mapOp{
+	opLoad, opStore, opLoadOrStore, opLoadAndDelete, opDelete,
+	opSwap, opCompareAndSwap, opCompareAndDelete, opLen, opClear,
+}
 
 // mapCall is a quick.Generator for calls on mapInterface.
-type mapCall[K comparable, V any] struct {
-	op mapOp
-	k  K
-	v  V
+type mapCall[K, V comparable] struct {
+	op  mapOp
+	k   K
+	v   V
+	old V
 }
 
 func (c mapCall[K, V]) apply(m mapInterface[K, V]) (any, bool) {
@@ -46,6 +58,17 @@ func (c mapCall[K, V]) apply(m mapInterface[K, V]) (any, bool) {
 	case opDelete:
 		m.Delete(c.k)
 		return nil, false
+	case opSwap:
+		return m.Swap(c.k, c.v)
+	case opCompareAndSwap:
+		return nil, m.CompareAndSwap(c.k, c.old, c.v)
+	case opCompareAndDelete:
+		return nil, m.CompareAndDelete(c.k, c.old)
+	case opLen:
+		return m.Len(), false
+	case opClear:
+		m.Clear()
+		return nil, false
 	default:
 		panic("invalid mapOp")
 	}
@@ -67,13 +90,18 @@ func randValue(r *rand.Rand) string {
 func (mapCall[K, V]) Generate(r *rand.Rand, size int) reflect.Value {
 	c := mapCall[mapOp, string]{op: mapOps[rand.Intn(len(mapOps))], k: mapOp(randValue(r))}
 	switch c.op {
-	case opStore, opLoadOrStore:
+	case opStore, opLoadOrStore, opSwap:
 		c.v = string(randValue(r))
+	case opCompareAndSwap:
+		c.old = string(randValue(r))
+		c.v = string(randValue(r))
+	case opCompareAndDelete:
+		c.old = string(randValue(r))
 	}
 	return reflect.ValueOf(c)
 }
 
-func applyCalls[K comparable, V any](m mapInterface[K, V], calls []mapCall[K, V]) (results []mapResult, final map[K]V) {
+func applyCalls[K, V comparable](m mapInterface[K, V], calls []mapCall[K, V]) (results []mapResult, final map[K]V) {
 	for _, c := range calls {
 		v, ok := c.apply(m)
 		results = append(results, mapResult{v, ok})
@@ -88,18 +116,22 @@ func applyCalls[K comparable, V any](m mapInterface[K, V], calls []mapCall[K, V]
 	return results, final
 }
 
-func applyMap[K comparable, V any](calls []mapCall[K, V]) ([]mapResult, map[K]V) {
-	return applyCalls[K, V](new(Map[K, V]), calls)
+func applyMap[K, V comparable](calls []mapCall[K, V]) ([]mapResult, map[K]V) {
+	return applyCalls[K, V](new(ComparableMap[K, V]), calls)
 }
 
-func applyRWMutexMap[K comparable, V any](calls []mapCall[K, V]) ([]mapResult, map[K]V) {
+func applyRWMutexMap[K, V comparable](calls []mapCall[K, V]) ([]mapResult, map[K]V) {
 	return applyCalls[K, V](new(RWMutexMap[K, V]), calls)
 }
 
-func applyDeepCopyMap[K comparable, V any](calls []mapCall[K, V]) ([]mapResult, map[K]V) {
+func applyDeepCopyMap[K, V comparable](calls []mapCall[K, V]) ([]mapResult, map[K]V) {
 	return applyCalls[K, V](new(DeepCopyMap[K, V]), calls)
 }
 
+func applyStdMap[K, V comparable](calls []mapCall[K, V]) ([]mapResult, map[K]V) {
+	return applyCalls[K, V](new(StdMap[K, V]), calls)
+}
+
 func TestMapMatchesRWMutex(t *testing.T) {
 	if err := quick.CheckEqual(applyMap[mapOp, string], applyRWMutexMap[mapOp, string], nil); err != nil {
 		t.Error(err)
@@ -112,6 +144,57 @@ func TestMapMatchesDeepCopy(t *testing.T) {
 	}
 }
 
+func TestMapMatchesStd(t *testing.T) {
+	if err := quick.CheckEqual(applyMap[mapOp, string], applyStdMap[mapOp, string], nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConcurrentLen(t *testing.T) {
+	const mapSize = 1 << 8
+
+	m := new(ComparableMap[int, int])
+	var wg sync.WaitGroup
+	for g := 0; g < runtime.GOMAXPROCS(0); g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(g)))
+			for i := 0; i < 1<<12; i++ {
+				k := r.Intn(mapSize)
+				switch r.Intn(7) {
+				case 0:
+					m.Store(k, i)
+				case 1:
+					m.LoadOrStore(k, i)
+				case 2:
+					m.Swap(k, i)
+				case 3:
+					m.Delete(k)
+				case 4:
+					m.CompareAndDelete(k, i-1)
+				case 5:
+					m.Load(k)
+				case 6:
+					if r.Intn(64) == 0 {
+						m.Clear()
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	n := 0
+	m.Range(func(int, int) bool {
+		n++
+		return true
+	})
+	if l := m.Len(); l != n {
+		t.Fatalf("Len() = %d, Range visited %d keys", l, n)
+	}
+}
+
 func TestConcurrentRange(t *testing.T) {
 	const mapSize = 1 << 10
 
@@ -171,3 +254,250 @@ func TestConcurrentRange(t *testing.T) {
 		}
 	}
 }
+
+func TestCompute(t *testing.T) {
+	var m Map[string, int]
+	incr := func(old int, loaded bool) (int, bool) { return old + 1, false }
+	if v, ok := m.Compute("a", incr); v != 1 || !ok {
+		t.Fatalf("Compute on missing key = %v, %v, want 1, true", v, ok)
+	}
+	if v, ok := m.Compute("a", incr); v != 2 || !ok {
+		t.Fatalf("Compute on present key = %v, %v, want 2, true", v, ok)
+	}
+	del := func(old int, loaded bool) (int, bool) { return 0, true }
+	if _, ok := m.Compute("a", del); ok {
+		t.Fatal("Compute reported a deleted key as present")
+	}
+	if _, ok := m.Load("a"); ok || m.Len() != 0 {
+		t.Fatalf("key survived deletion by Compute, Len() = %d", m.Len())
+	}
+	if _, ok := m.Update("a", func(old int) int { return old + 1 }); ok {
+		t.Fatal("Update stored a missing key")
+	}
+	m.Store("a", 41)
+	if v, ok := m.Update("a", func(old int) int { return old + 1 }); v != 42 || !ok {
+		t.Fatalf("Update = %v, %v, want 42, true", v, ok)
+	}
+}
+
+func TestConcurrentCompute(t *testing.T) {
+	const (
+		keys  = 8
+		iters = 1 << 10
+	)
+
+	var m Map[int, int]
+	procs := runtime.GOMAXPROCS(0)
+	var wg sync.WaitGroup
+	for g := 0; g < procs; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < iters; i++ {
+				k := i % keys
+				if (i/keys+g)%2 == 0 {
+					m.Compute(k, func(old int, _ bool) (int, bool) { return old + 1, false })
+				} else {
+					m.Update(k, func(old int) int { return old + 1 })
+				}
+				// Force some keys through the dirty map as well.
+				if i%64 == 0 {
+					m.Range(func(int, int) bool { return true })
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	total := 0
+	m.Range(func(_, v int) bool {
+		total += v
+		return true
+	})
+	// Update does nothing on a missing key, so only a lower bound and an
+	// upper bound on the total are known.
+	if total < procs*iters/2 || total > procs*iters {
+		t.Fatalf("total of computed counters = %d, want in [%d, %d]", total, procs*iters/2, procs*iters)
+	}
+	if m.Len() != keys {
+		t.Fatalf("Len() = %d, want %d", m.Len(), keys)
+	}
+}
+
+func TestLoadOrComputeOnce(t *testing.T) {
+	const keys = 16
+
+	var (
+		m     Map[int, int]
+		calls [keys]int64
+		wg    sync.WaitGroup
+	)
+	for g := 0; g < runtime.GOMAXPROCS(0)*2; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < keys; k++ {
+				k := k
+				v, _ := m.LoadOrCompute(k, func() int {
+					atomic.AddInt64(&calls[k], 1)
+					return k * k
+				})
+				if v != k*k {
+					t.Errorf("LoadOrCompute(%d) = %d, want %d", k, v, k*k)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for k := range calls {
+		if n := atomic.LoadInt64(&calls[k]); n != 1 {
+			t.Errorf("constructor for key %d called %d times, want 1", k, n)
+		}
+	}
+}
+
+type bench struct {
+	setup func(*testing.B, mapInterface[int, int])
+	perG  func(b *testing.B, pb *testing.PB, i int, m mapInterface[int, int])
+}
+
+// benchMaps construct the maps compared by benchMap.
+var benchMaps = [...]func() mapInterface[int, int]{
+	func() mapInterface[int, int] { return new(ComparableMap[int, int]) },
+	func() mapInterface[int, int] { return NewComparableShardedMap[int, int](intHash) },
+	func() mapInterface[int, int] { return new(StdMap[int, int]) },
+}
+
+// benchMap runs a benchmark against Map, ShardedMap and the standard
+// sync.Map.
+func benchMap(b *testing.B, bench bench) {
+	for _, newMap := range benchMaps {
+		name := fmt.Sprintf("%T", newMap())
+		name = name[strings.LastIndexByte(name, '.')+1 : strings.IndexByte(name, '[')]
+		b.Run(name, func(b *testing.B) {
+			m := newMap()
+			if bench.setup != nil {
+				bench.setup(b, m)
+			}
+
+			b.ResetTimer()
+
+			var i int64
+			b.RunParallel(func(pb *testing.PB) {
+				id := int(atomic.AddInt64(&i, 1) - 1)
+				bench.perG(b, pb, id*b.N, m)
+			})
+		})
+	}
+}
+
+func BenchmarkLoadMostlyHits(b *testing.B) {
+	const hits, misses = 1023, 1
+
+	benchMap(b, bench{
+		setup: func(_ *testing.B, m mapInterface[int, int]) {
+			for i := 0; i < hits; i++ {
+				m.LoadOrStore(i, i)
+			}
+			// Prime the map to get it into a steady state.
+			for i := 0; i < hits*2; i++ {
+				m.Load(i % hits)
+			}
+		},
+
+		perG: func(b *testing.B, pb *testing.PB, i int, m mapInterface[int, int]) {
+			for ; pb.Next(); i++ {
+				m.Load(i % (hits + misses))
+			}
+		},
+	})
+}
+
+func BenchmarkLoadMostlyMisses(b *testing.B) {
+	const hits, misses = 1, 1023
+
+	benchMap(b, bench{
+		setup: func(_ *testing.B, m mapInterface[int, int]) {
+			for i := 0; i < hits; i++ {
+				m.LoadOrStore(i, i)
+			}
+			// Prime the map to get it into a steady state.
+			for i := 0; i < hits*2; i++ {
+				m.Load(i % hits)
+			}
+		},
+
+		perG: func(b *testing.B, pb *testing.PB, i int, m mapInterface[int, int]) {
+			for ; pb.Next(); i++ {
+				m.Load(i % (hits + misses))
+			}
+		},
+	})
+}
+
+func BenchmarkLoadOrStoreCollision(b *testing.B) {
+	benchMap(b, bench{
+		setup: func(_ *testing.B, m mapInterface[int, int]) {
+			m.LoadOrStore(0, 0)
+		},
+
+		perG: func(b *testing.B, pb *testing.PB, i int, m mapInterface[int, int]) {
+			for ; pb.Next(); i++ {
+				m.LoadOrStore(0, 0)
+			}
+		},
+	})
+}
+
+func BenchmarkStoreExisting(b *testing.B) {
+	const size = 1 << 10
+
+	benchMap(b, bench{
+		setup: func(_ *testing.B, m mapInterface[int, int]) {
+			for i := 0; i < size; i++ {
+				m.Store(i, i)
+			}
+		},
+
+		perG: func(b *testing.B, pb *testing.PB, i int, m mapInterface[int, int]) {
+			for ; pb.Next(); i++ {
+				m.Store(i%size, i)
+			}
+		},
+	})
+}
+
+func BenchmarkRange(b *testing.B) {
+	const mapSize = 1 << 10
+
+	benchMap(b, bench{
+		setup: func(_ *testing.B, m mapInterface[int, int]) {
+			for i := 0; i < mapSize; i++ {
+				m.Store(i, i)
+			}
+		},
+
+		perG: func(b *testing.B, pb *testing.PB, i int, m mapInterface[int, int]) {
+			for ; pb.Next(); i++ {
+				m.Range(func(_, _ int) bool { return true })
+			}
+		},
+	})
+}
+
+func BenchmarkAdversarialAlloc(b *testing.B) {
+	benchMap(b, bench{
+		perG: func(b *testing.B, pb *testing.PB, i int, m mapInterface[int, int]) {
+			var stores, loadsSinceStore int
+			for ; pb.Next(); i++ {
+				m.Load(i)
+				if loadsSinceStore++; loadsSinceStore > stores {
+					m.LoadOrStore(i, stores)
+					loadsSinceStore = 0
+					stores++
+				}
+			}
+		},
+	})
+}
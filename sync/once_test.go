@@ -0,0 +1,64 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sync
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOnceValue(t *testing.T) {
+	calls := 0
+	f := OnceValue(func() int {
+		calls++
+		return calls
+	})
+	for i := 0; i < 3; i++ {
+		if v := f(); v != 1 {
+			t.Fatalf("call %d returned %d, want 1", i, v)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("f called %d times, want 1", calls)
+	}
+}
+
+func TestOnceValues(t *testing.T) {
+	want := errors.New("failed")
+	calls := 0
+	f := OnceValues(func() (int, error) {
+		calls++
+		return 42, want
+	})
+	for i := 0; i < 3; i++ {
+		if v, err := f(); v != 42 || err != want {
+			t.Fatalf("call %d returned %d, %v, want 42, %v", i, v, err, want)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("f called %d times, want 1", calls)
+	}
+}
+
+func TestOnceValuePanic(t *testing.T) {
+	calls := 0
+	f := OnceValue(func() int {
+		calls++
+		panic("boom")
+	})
+	for i := 0; i < 3; i++ {
+		func() {
+			defer func() {
+				if r := recover(); r != "boom" {
+					t.Fatalf("call %d recovered %v, want boom", i, r)
+				}
+			}()
+			f()
+		}()
+	}
+	if calls != 1 {
+		t.Fatalf("f called %d times, want 1", calls)
+	}
+}
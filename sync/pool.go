@@ -0,0 +1,114 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sync
+
+import (
+	"math/bits"
+	"sync"
+	"unsafe"
+)
+
+// Pool is a type-safe set of temporary objects of type T. See sync.Pool
+// for how pooled objects are retained. As with sync.Pool, T should be a
+// pointer type, otherwise every Put allocates.
+//
+// The zero value is an empty pool whose Get returns the zero value of T.
+type Pool[T any] struct {
+	// New optionally specifies a function to generate a value when Get
+	// finds the pool empty.
+	New func() T
+	// Reset optionally specifies a function to reset a value before it
+	// is put back into the pool.
+	Reset func(T)
+
+	p sync.Pool
+}
+
+// Get selects an arbitrary item from the pool, removes it from the pool
+// and returns it. If the pool is empty, Get returns the result of New, or
+// the zero value of T if New is nil.
+func (p *Pool[T]) Get() T {
+	if x, ok := p.p.Get().(T); ok {
+		return x
+	}
+	if p.New != nil {
+		return p.New()
+	}
+	var zero T
+	return zero
+}
+
+// Put resets x with Reset, if any, and adds it to the pool.
+func (p *Pool[T]) Put(x T) {
+	if p.Reset != nil {
+		p.Reset(x)
+	}
+	p.p.Put(x)
+}
+
+// SlicePool is a set of temporary slices of E, grouped by capacity into
+// size classes of powers of two. A SlicePool must be created with
+// NewSlicePool.
+//
+// Pooled slices are not cleared, so the elements of a slice returned by
+// Get are unspecified, and elements holding pointers keep what they point
+// to alive until the slice is reused.
+type SlicePool[E any] struct {
+	minShift int
+	// pools[i] holds the first elements of slices whose capacity is
+	// exactly 1<<(minShift+i), which is enough to rebuild them without
+	// allocating a slice header on Put.
+	pools []sync.Pool
+}
+
+// NewSlicePool returns a SlicePool that pools slices of capacity from
+// minCap to maxCap, both rounded up to a power of two.
+func NewSlicePool[E any](minCap, maxCap int) *SlicePool[E] {
+	if minCap < 1 || maxCap < minCap {
+		panic("sync: invalid SlicePool capacities")
+	}
+	minShift := bits.Len(uint(minCap - 1))
+	maxShift := bits.Len(uint(maxCap - 1))
+	return &SlicePool[E]{
+		minShift: minShift,
+		pools:    make([]sync.Pool, maxShift-minShift+1),
+	}
+}
+
+// Get returns a slice of length n. Its capacity is n rounded up to the
+// size class of n, unless n is beyond the largest size class, in which
+// case the slice is freshly allocated with capacity n.
+func (p *SlicePool[E]) Get(n int) []E {
+	if n < 0 {
+		panic("sync: negative SlicePool length")
+	}
+	shift := bits.Len(uint(n - 1))
+	if n == 0 || shift < p.minShift {
+		shift = p.minShift
+	}
+	i := shift - p.minShift
+	if i >= len(p.pools) {
+		return make([]E, n)
+	}
+	if e, ok := p.pools[i].Get().(*E); ok {
+		return unsafe.Slice(e, 1<<shift)[:n]
+	}
+	return make([]E, n, 1<<shift)
+}
+
+// Put adds s to the pool of the largest size class that its capacity
+// can hold. Slices smaller than the smallest size class or larger than
+// twice the largest one are dropped. s must not be used after Put.
+func (p *SlicePool[E]) Put(s []E) {
+	c := cap(s)
+	if c < 1<<p.minShift {
+		return
+	}
+	i := bits.Len(uint(c)) - 1 - p.minShift
+	if i >= len(p.pools) {
+		return
+	}
+	p.pools[i].Put(&s[:c][0])
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sync
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPool(t *testing.T) {
+	var p Pool[*bytes.Buffer]
+	if b := p.Get(); b != nil {
+		t.Fatalf("Get from an empty pool without New = %v, want nil", b)
+	}
+
+	news := 0
+	p.New = func() *bytes.Buffer {
+		news++
+		return new(bytes.Buffer)
+	}
+	p.Reset = (*bytes.Buffer).Reset
+
+	b := p.Get()
+	if b == nil || news != 1 {
+		t.Fatalf("Get did not use New, got %v after %d calls", b, news)
+	}
+	b.WriteString("dirty")
+	p.Put(b)
+	if b.Len() != 0 {
+		t.Fatalf("Put did not reset the buffer, it holds %q", b.String())
+	}
+	// The pool may drop b at any time, but whatever it returns is reset.
+	if b := p.Get(); b.Len() != 0 {
+		t.Fatalf("Get returned a dirty buffer %q", b.String())
+	}
+}
+
+func TestSlicePool(t *testing.T) {
+	p := NewSlicePool[byte](100, 1000)
+
+	for _, tt := range []struct{ n, cap int }{
+		{0, 128},
+		{1, 128},
+		{128, 128},
+		{129, 256},
+		{1000, 1024},
+		{1025, 1025},
+	} {
+		s := p.Get(tt.n)
+		if len(s) != tt.n || cap(s) != tt.cap {
+			t.Errorf("Get(%d) returned len %d, cap %d, want len %d, cap %d", tt.n, len(s), cap(s), tt.n, tt.cap)
+		}
+		p.Put(s)
+	}
+
+	// A slice is reused by the largest class it can hold.
+	for i := 0; i < 100; i++ {
+		s := make([]byte, 300)
+		s[0] = 42
+		p.Put(s)
+		if r := p.Get(200); cap(r) != 256 {
+			t.Fatalf("Get(200) after Put of capacity 300 returned cap %d", cap(r))
+		} else if r[0] == 42 {
+			return
+		}
+	}
+	t.Fatal("SlicePool never reused a put slice")
+}
+
+func BenchmarkSlicePool(b *testing.B) {
+	p := NewSlicePool[byte](64, 1<<16)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		n := 1
+		for pb.Next() {
+			s := p.Get(n)
+			p.Put(s)
+			n = n*7%(1<<16) + 1
+		}
+	})
+}
@@ -0,0 +1,272 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sync
+
+import (
+	"runtime"
+	"sync"
+)
+
+// ConcurrentMap is the method set shared by Map and ShardedMap, so that
+// either can be used where the other is expected.
+type ConcurrentMap[K comparable, V any] interface {
+	Load(key K) (value V, ok bool)
+	Store(key K, value V)
+	LoadOrStore(key K, value V) (actual V, loaded bool)
+	LoadAndDelete(key K) (value V, loaded bool)
+	Delete(key K)
+	Swap(key K, value V) (previous V, loaded bool)
+	Compute(key K, f func(old V, loaded bool) (new V, delete bool)) (actual V, ok bool)
+	LoadOrCompute(key K, new func() V) (actual V, loaded bool)
+	Update(key K, f func(old V) V) (value V, ok bool)
+	Len() int
+	Clear()
+	Range(f func(key K, value V) bool)
+}
+
+var (
+	_ ConcurrentMap[int, int] = (*Map[int, int])(nil)
+	_ ConcurrentMap[int, int] = (*ShardedMap[int, int])(nil)
+)
+
+// ShardedMap is a concurrent-safe map[k]v container that spreads its keys
+// over a fixed number of independently locked shards. Unlike Map, whose
+// writes to new keys go through a single lock and periodically copy the
+// whole map, it suits workloads that write as often as they read.
+//
+// A ShardedMap must be created with NewShardedMap.
+type ShardedMap[K comparable, V any] struct {
+	shards []shard[K, V]
+	hash   func(K) uint64
+}
+
+type shard[K comparable, V any] struct {
+	mu sync.RWMutex
+	m  map[K]V
+	_  [64]byte // keeps adjacent shards on different cache lines
+}
+
+type shardOptions[K comparable] struct {
+	shards int
+}
+
+// ShardOption configures a ShardedMap.
+type ShardOption[K comparable] func(o *shardOptions[K])
+
+// WithShards sets the number of shards, which is rounded up to a power of
+// two. It defaults to four times GOMAXPROCS.
+func WithShards[K comparable](n int) ShardOption[K] {
+	return func(o *shardOptions[K]) {
+		o.shards = n
+	}
+}
+
+// NewShardedMap returns an empty ShardedMap whose keys are assigned to
+// shards by hash, e.g. a hash/maphash of the key. Equal keys must have
+// equal hashes.
+func NewShardedMap[K comparable, V any](hash func(K) uint64, opts ...ShardOption[K]) *ShardedMap[K, V] {
+	o := shardOptions[K]{shards: 4 * runtime.GOMAXPROCS(0)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	n := 1
+	for n < o.shards {
+		n <<= 1
+	}
+	m := &ShardedMap[K, V]{shards: make([]shard[K, V], n), hash: hash}
+	for i := range m.shards {
+		m.shards[i].m = make(map[K]V)
+	}
+	return m
+}
+
+func (m *ShardedMap[K, V]) shard(key K) *shard[K, V] {
+	return &m.shards[m.hash(key)&uint64(len(m.shards)-1)]
+}
+
+func (m *ShardedMap[K, V]) Load(key K) (value V, ok bool) {
+	s := m.shard(key)
+	s.mu.RLock()
+	value, ok = s.m[key]
+	s.mu.RUnlock()
+	return value, ok
+}
+
+func (m *ShardedMap[K, V]) Store(key K, value V) {
+	s := m.shard(key)
+	s.mu.Lock()
+	s.m[key] = value
+	s.mu.Unlock()
+}
+
+func (m *ShardedMap[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if actual, loaded = s.m[key]; loaded {
+		return actual, true
+	}
+	s.m[key] = value
+	return value, false
+}
+
+func (m *ShardedMap[K, V]) LoadAndDelete(key K) (value V, loaded bool) {
+	s := m.shard(key)
+	s.mu.Lock()
+	value, loaded = s.m[key]
+	delete(s.m, key)
+	s.mu.Unlock()
+	return value, loaded
+}
+
+func (m *ShardedMap[K, V]) Delete(key K) {
+	m.LoadAndDelete(key)
+}
+
+// Swap stores value for key and returns the previous value if any.
+// The loaded result reports whether the key was present.
+func (m *ShardedMap[K, V]) Swap(key K, value V) (previous V, loaded bool) {
+	s := m.shard(key)
+	s.mu.Lock()
+	previous, loaded = s.m[key]
+	s.m[key] = value
+	s.mu.Unlock()
+	return previous, loaded
+}
+
+// Compute atomically replaces the value for key with the result of f, or
+// deletes the key if f reports delete. f is called exactly once, with the
+// key's shard locked, so it must not access the map.
+func (m *ShardedMap[K, V]) Compute(key K, f func(old V, loaded bool) (new V, delete bool)) (actual V, ok bool) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, loaded := s.m[key]
+	v, del := f(old, loaded)
+	if del {
+		delete(s.m, key)
+		return actual, false
+	}
+	s.m[key] = v
+	return v, true
+}
+
+// LoadOrCompute returns the existing value for key if present. Otherwise,
+// it stores and returns the value returned by new, which is called at
+// most once with the key's shard locked.
+func (m *ShardedMap[K, V]) LoadOrCompute(key K, new func() V) (actual V, loaded bool) {
+	if v, ok := m.Load(key); ok {
+		return v, true
+	}
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if actual, loaded = s.m[key]; loaded {
+		return actual, true
+	}
+	actual = new()
+	s.m[key] = actual
+	return actual, false
+}
+
+// Update atomically replaces the value for key with f applied to it, if
+// the key is present. f is called with the key's shard locked, so it
+// must not access the map.
+func (m *ShardedMap[K, V]) Update(key K, f func(old V) V) (value V, ok bool) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if value, ok = s.m[key]; !ok {
+		return value, false
+	}
+	value = f(value)
+	s.m[key] = value
+	return value, true
+}
+
+// Len returns the number of keys in the map. Shards are counted one at a
+// time, so concurrent writes may or may not be reflected.
+func (m *ShardedMap[K, V]) Len() int {
+	n := 0
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		n += len(s.m)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// Clear deletes all the keys.
+func (m *ShardedMap[K, V]) Clear() {
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.Lock()
+		s.m = make(map[K]V)
+		s.mu.Unlock()
+	}
+}
+
+// Range calls f sequentially for each key and value present in the map.
+// If f returns false, Range stops the iteration.
+//
+// Each shard is copied before f is called on its keys, so f may modify
+// the map. As with Map, Range does not correspond to a consistent snapshot.
+func (m *ShardedMap[K, V]) Range(f func(key K, value V) bool) {
+	var keys []K
+	var values []V
+	for i := range m.shards {
+		s := &m.shards[i]
+		keys, values = keys[:0], values[:0]
+		s.mu.RLock()
+		for k, v := range s.m {
+			keys = append(keys, k)
+			values = append(values, v)
+		}
+		s.mu.RUnlock()
+		for j := range keys {
+			if !f(keys[j], values[j]) {
+				return
+			}
+		}
+	}
+}
+
+// ComparableShardedMap is a ShardedMap whose values are comparable, which
+// additionally supports compare-and-swap operations.
+type ComparableShardedMap[K, V comparable] struct {
+	ShardedMap[K, V]
+}
+
+// NewComparableShardedMap returns an empty ComparableShardedMap whose
+// keys are assigned to shards by hash, as for NewShardedMap.
+func NewComparableShardedMap[K, V comparable](hash func(K) uint64, opts ...ShardOption[K]) *ComparableShardedMap[K, V] {
+	return &ComparableShardedMap[K, V]{*NewShardedMap[K, V](hash, opts...)}
+}
+
+// CompareAndSwap swaps the old and new values for key if the value
+// stored in the map is equal to old.
+func (m *ComparableShardedMap[K, V]) CompareAndSwap(key K, old, new V) (swapped bool) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.m[key]; !ok || v != old {
+		return false
+	}
+	s.m[key] = new
+	return true
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+func (m *ComparableShardedMap[K, V]) CompareAndDelete(key K, old V) (deleted bool) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.m[key]; !ok || v != old {
+		return false
+	}
+	delete(s.m, key)
+	return true
+}
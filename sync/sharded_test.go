@@ -0,0 +1,118 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sync
+
+import (
+	"hash/maphash"
+	"testing"
+	"testing/quick"
+)
+
+var seed = maphash.MakeSeed()
+
+// stringHash is a randomly seeded hash of string keys.
+func stringHash[K ~string](k K) uint64 {
+	var h maphash.Hash
+	h.SetSeed(seed)
+	h.WriteString(string(k))
+	return h.Sum64()
+}
+
+// intHash spreads int keys by Fibonacci hashing.
+func intHash(k int) uint64 {
+	return uint64(k) * 0x9e3779b97f4a7c15
+}
+
+func applyShardedMap(calls []mapCall[mapOp, string]) ([]mapResult, map[mapOp]string) {
+	return applyCalls[mapOp, string](NewComparableShardedMap[mapOp, string](stringHash[mapOp], WithShards[mapOp](4)), calls)
+}
+
+func TestShardedMapMatchesRWMutex(t *testing.T) {
+	if err := quick.CheckEqual(applyShardedMap, applyRWMutexMap[mapOp, string], nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestShardedMapOptions(t *testing.T) {
+	m := NewShardedMap[int, int](func(k int) uint64 { return uint64(k) }, WithShards[int](5))
+	if len(m.shards) != 8 {
+		t.Fatalf("got %d shards, want 5 rounded up to 8", len(m.shards))
+	}
+	for i := 0; i < 16; i++ {
+		m.Store(i, i)
+	}
+	for i := range m.shards {
+		if n := len(m.shards[i].m); n != 2 {
+			t.Errorf("shard %d holds %d keys, want 2", i, n)
+		}
+	}
+	if m.Len() != 16 {
+		t.Fatalf("Len() = %d, want 16", m.Len())
+	}
+
+	// A degenerate hash still gives a correct map.
+	one := NewShardedMap[string, int](func(string) uint64 { return 0 })
+	one.Store("a", 1)
+	one.Store("b", 2)
+	if v, ok := one.Load("b"); v != 2 || !ok {
+		t.Fatalf("Load(b) = %v, %v, want 2, true", v, ok)
+	}
+}
+
+func TestShardedMapRangeMutate(t *testing.T) {
+	m := NewShardedMap[int, int](intHash)
+	for i := 0; i < 64; i++ {
+		m.Store(i, i)
+	}
+	m.Range(func(k, _ int) bool {
+		m.Delete(k)
+		return true
+	})
+	if m.Len() != 0 {
+		t.Fatalf("Len() = %d after deleting every key in Range", m.Len())
+	}
+}
+
+func BenchmarkStoreDistinct(b *testing.B) {
+	benchMap(b, bench{
+		perG: func(b *testing.B, pb *testing.PB, i int, m mapInterface[int, int]) {
+			for ; pb.Next(); i++ {
+				m.Store(i, i)
+			}
+		},
+	})
+}
+
+func BenchmarkWriteHeavy(b *testing.B) {
+	const size = 1 << 12
+
+	benchMap(b, bench{
+		perG: func(b *testing.B, pb *testing.PB, i int, m mapInterface[int, int]) {
+			for ; pb.Next(); i++ {
+				k := i % size
+				switch i % 4 {
+				case 0:
+					m.Load(k)
+				case 1, 2:
+					m.Store(k, i)
+				case 3:
+					m.Delete(k)
+				}
+			}
+		},
+	})
+}
+
+func BenchmarkSwapCollision(b *testing.B) {
+	const size = 1 << 10
+
+	benchMap(b, bench{
+		perG: func(b *testing.B, pb *testing.PB, i int, m mapInterface[int, int]) {
+			for ; pb.Next(); i++ {
+				m.Swap(i%size, i)
+			}
+		},
+	})
+}
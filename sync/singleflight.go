@@ -0,0 +1,122 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// errGoexit records that the function of a call called runtime.Goexit.
+var errGoexit = errors.New("sync: runtime.Goexit was called")
+
+// PanicError is the value with which the callers of Group.Do panic when
+// the function executed on behalf of them panicked.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("%v\n\n%s", p.Value, p.Stack)
+}
+
+// call is an in-flight or completed Group.Do call.
+type call[V any] struct {
+	wg sync.WaitGroup
+
+	// val and err are written once before wg is done and only read
+	// after wg is done.
+	val V
+	err error
+
+	// dups is the number of callers waiting for the call, guarded by
+	// the mutex of the group.
+	dups int
+}
+
+// Group deduplicates concurrent calls of functions by key. The zero value
+// is ready for use.
+type Group[K comparable, V any] struct {
+	mu sync.Mutex
+	m  map[K]*call[V]
+}
+
+// Do executes and returns the results of fn, making sure that only one
+// execution is in flight for a given key at a time. If a duplicate call
+// comes in, the duplicate caller waits for the original to complete and
+// receives the same results. The shared result reports whether the
+// results were given to more than one caller.
+//
+// If fn panics, the original caller panics with the same value and the
+// duplicate callers panic with a *PanicError.
+func (g *Group[K, V]) Do(key K, fn func() (V, error)) (v V, err error, shared bool) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[K]*call[V])
+	}
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		g.mu.Unlock()
+		c.wg.Wait()
+
+		if e, ok := c.err.(*PanicError); ok {
+			panic(e)
+		} else if c.err == errGoexit {
+			runtime.Goexit()
+		}
+		return c.val, c.err, true
+	}
+	c := new(call[V])
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	g.doCall(c, key, fn)
+	return c.val, c.err, c.dups > 0
+}
+
+func (g *Group[K, V]) doCall(c *call[V], key K, fn func() (V, error)) {
+	normalReturn := false
+	defer func() {
+		var p *PanicError
+		if !normalReturn {
+			if r := recover(); r != nil {
+				p = &PanicError{Value: r, Stack: debug.Stack()}
+				c.err = p
+			} else {
+				c.err = errGoexit
+			}
+		}
+
+		g.mu.Lock()
+		c.wg.Done()
+		if g.m[key] == c {
+			delete(g.m, key)
+		}
+		g.mu.Unlock()
+
+		// Keep the original panic value in the goroutine that panicked,
+		// a Goexit simply continues to unwind.
+		if p != nil {
+			panic(p.Value)
+		}
+	}()
+
+	c.val, c.err = fn()
+	normalReturn = true
+}
+
+// Forget tells the group to forget about a key. Future calls to Do for
+// this key call the function rather than waiting for an earlier call to
+// complete.
+func (g *Group[K, V]) Forget(key K) {
+	g.mu.Lock()
+	delete(g.m, key)
+	g.mu.Unlock()
+}
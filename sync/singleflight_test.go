@@ -0,0 +1,136 @@
+// Copyright 2020 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sync
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupDo(t *testing.T) {
+	var g Group[string, int]
+	v, err, shared := g.Do("key", func() (int, error) { return 42, nil })
+	if v != 42 || err != nil || shared {
+		t.Fatalf("Do = %v, %v, %v, want 42, <nil>, false", v, err, shared)
+	}
+
+	want := errors.New("failed")
+	_, err, _ = g.Do("key", func() (int, error) { return 0, want })
+	if err != want {
+		t.Fatalf("Do error = %v, want %v", err, want)
+	}
+}
+
+func TestGroupDoDupSuppress(t *testing.T) {
+	const n = 10
+
+	var (
+		g       Group[string, int]
+		calls   int32
+		started = make(chan struct{})
+		release = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+	fn := func() (int, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return 42, nil
+	}
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err, _ := g.Do("key", fn)
+			if v != 42 || err != nil {
+				t.Errorf("Do = %v, %v, want 42, <nil>", v, err)
+			}
+		}()
+	}
+	<-started
+	// Give the other callers a chance to join the call in flight.
+	for {
+		g.mu.Lock()
+		dups := g.m["key"].dups
+		g.mu.Unlock()
+		if dups == n-1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("fn called %d times, want 1", got)
+	}
+}
+
+func TestGroupForget(t *testing.T) {
+	var g Group[string, int]
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		g.Do("key", func() (int, error) {
+			close(started)
+			<-release
+			return 1, nil
+		})
+		close(done)
+	}()
+	<-started
+
+	g.Forget("key")
+	v, _, shared := g.Do("key", func() (int, error) { return 2, nil })
+	if v != 2 || shared {
+		t.Fatalf("Do after Forget = %v, shared %v, want 2, false", v, shared)
+	}
+	close(release)
+	<-done
+}
+
+func TestGroupPanic(t *testing.T) {
+	var g Group[string, int]
+
+	started := make(chan struct{})
+	dup := make(chan any)
+	go func() {
+		<-started
+		defer func() { dup <- recover() }()
+		g.Do("key", func() (int, error) { return 0, nil })
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("original caller recovered %v, want boom", r)
+			}
+		}()
+		g.Do("key", func() (int, error) {
+			close(started)
+			for {
+				g.mu.Lock()
+				dups := g.m["key"].dups
+				g.mu.Unlock()
+				if dups == 1 {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+			panic("boom")
+		})
+	}()
+
+	if p, ok := (<-dup).(*PanicError); !ok || p.Value != "boom" || len(p.Stack) == 0 {
+		t.Fatalf("duplicate caller recovered %v, want a *PanicError of boom", p)
+	}
+}